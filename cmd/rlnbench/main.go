@@ -0,0 +1,347 @@
+// rlnbench measures the duration of the main RLN operations, so zerokit versions and
+// architectures can be compared. Every measurement is printed as a JSON object per line:
+//
+//	rlnbench [-depths 20,15] [-modes HighThroughput,LowSpace] [-batches 1,10,100,1000] [-iterations 20] [-proof-iterations 3]
+//
+// zerokit keeps a single witness calculator per process, and prints diagnostic text to the
+// standard output, so each depth is measured in a separate process whose output is filtered
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/waku-org/go-zerokit-rln/rln"
+)
+
+type options struct {
+	depths          []int
+	modes           []rln.TreeMode
+	batches         []int
+	iterations      int
+	proofIterations int
+}
+
+// result is a line of the output
+type result struct {
+	GOOS       string `json:"goos"`
+	GOARCH     string `json:"goarch"`
+	Depth      int    `json:"depth"`
+	Mode       string `json:"mode"`
+	Operation  string `json:"operation"`
+	BatchSize  int    `json:"batch_size,omitempty"`
+	Iterations int    `json:"iterations"`
+	MeanNs     int64  `json:"mean_ns"`
+	MinNs      int64  `json:"min_ns"`
+	MaxNs      int64  `json:"max_ns"`
+}
+
+func main() {
+	depths := flag.String("depths", strconv.Itoa(int(rln.DefaultTreeDepth)), "comma separated tree depths")
+	modes := flag.String("modes", fmt.Sprintf("%s,%s", rln.HighThroughput, rln.LowSpace), "comma separated tree modes")
+	batches := flag.String("batches", "1,10,100,1000", "comma separated batch sizes used with InsertMembers")
+	iterations := flag.Int("iterations", 20, "iterations of each operation")
+	proofIterations := flag.Int("proof-iterations", 3, "iterations of the proof generation, which is much slower")
+	runDepth := flag.Int("run-depth", 0, "measure a single depth in this process, used internally")
+	flag.Parse()
+
+	opts, err := parseOptions(*depths, *modes, *batches, *iterations, *proofIterations)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+
+	if *runDepth != 0 {
+		err = runDepthBenchmarks(rln.TreeDepth(*runDepth), opts, json.NewEncoder(os.Stdout))
+	} else {
+		err = runChildren(opts)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func parseOptions(depths string, modes string, batches string, iterations int, proofIterations int) (options, error) {
+	opts := options{
+		iterations:      iterations,
+		proofIterations: proofIterations,
+	}
+
+	if iterations <= 0 || proofIterations <= 0 {
+		return options{}, errors.New("the number of iterations must be positive")
+	}
+
+	var err error
+	if opts.depths, err = parseInts(depths); err != nil {
+		return options{}, fmt.Errorf("invalid depths: %w", err)
+	}
+	if opts.batches, err = parseInts(batches); err != nil {
+		return options{}, fmt.Errorf("invalid batches: %w", err)
+	}
+
+	for _, mode := range strings.Split(modes, ",") {
+		mode := rln.TreeMode(strings.TrimSpace(mode))
+		if mode != rln.HighThroughput && mode != rln.LowSpace {
+			return options{}, fmt.Errorf("invalid tree mode: %s", mode)
+		}
+		opts.modes = append(opts.modes, mode)
+	}
+
+	return opts, nil
+}
+
+func parseInts(s string) ([]int, error) {
+	var result []int
+	for _, v := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		if n <= 0 {
+			return nil, fmt.Errorf("%d is not positive", n)
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+// runChildren measures each depth in a new process, forwarding the results to the standard
+// output and anything else printed by zerokit to the standard error
+func runChildren(opts options) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	for _, depth := range opts.depths {
+		args := append([]string{"-run-depth", strconv.Itoa(depth)}, os.Args[1:]...)
+		cmd := exec.Command(executable, args...)
+		cmd.Stderr = os.Stderr
+
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+
+		if err := filterResults(stdout, os.Stdout, os.Stderr); err != nil {
+			return err
+		}
+
+		if err := cmd.Wait(); err != nil {
+			return fmt.Errorf("depth %d: %w", depth, err)
+		}
+	}
+
+	return nil
+}
+
+func filterResults(r io.Reader, results io.Writer, other io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		out := other
+		if len(line) != 0 && line[0] == '{' && json.Valid(line) {
+			out = results
+		}
+		if _, err := fmt.Fprintf(out, "%s\n", line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func runDepthBenchmarks(depth rln.TreeDepth, opts options, encoder *json.Encoder) error {
+	for _, mode := range opts.modes {
+		err := benchmark(depth, mode, opts, func(res result) error {
+			res.GOOS = runtime.GOOS
+			res.GOARCH = runtime.GOARCH
+			res.Depth = int(depth)
+			res.Mode = string(mode)
+			return encoder.Encode(res)
+		})
+		if err != nil {
+			return fmt.Errorf("depth %d, mode %s: %w", depth, mode, err)
+		}
+	}
+	return nil
+}
+
+// measure runs f n times and returns the mean, minimum and maximum duration. f can exclude
+// its preparation from the measurement by calling the function it receives
+func measure(operation string, n int, f func(i int, start func()) error) (result, error) {
+	res := result{Operation: operation, Iterations: n}
+	var total time.Duration
+	for i := 0; i < n; i++ {
+		begin := time.Now()
+		if err := f(i, func() { begin = time.Now() }); err != nil {
+			return result{}, fmt.Errorf("%s: %w", operation, err)
+		}
+		elapsed := time.Since(begin)
+
+		total += elapsed
+		if i == 0 || elapsed.Nanoseconds() < res.MinNs {
+			res.MinNs = elapsed.Nanoseconds()
+		}
+		if elapsed.Nanoseconds() > res.MaxNs {
+			res.MaxNs = elapsed.Nanoseconds()
+		}
+	}
+	res.MeanNs = total.Nanoseconds() / int64(n)
+	return res, nil
+}
+
+func benchmark(depth rln.TreeDepth, mode rln.TreeMode, opts options, emit func(result) error) error {
+	dir, err := os.MkdirTemp("", "rlnbench")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	config := rln.DefaultTreeConfig()
+	config.Mode = mode
+	config.Path = filepath.Join(dir, "tree")
+
+	r, err := rln.NewWithConfig(depth, &config)
+	if err != nil {
+		return err
+	}
+
+	// Fixed seed, so every run inserts the same leaves and reads the same proofs
+	random := rand.New(rand.NewSource(1))
+
+	var member *rln.IdentityCredential
+	res, err := measure("key_gen", opts.iterations, func(i int, _ func()) error {
+		key, err := r.MembershipKeyGen()
+		if i == 0 {
+			member = key
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := emit(res); err != nil {
+		return err
+	}
+
+	if err := r.InsertMember(member.IDCommitment); err != nil {
+		return err
+	}
+
+	capacity := uint64(1) << depth
+	for _, batch := range opts.batches {
+		iterations := opts.iterations
+		if available := (capacity - uint64(r.LeavesSet())) / uint64(batch); available < uint64(iterations) {
+			iterations = int(available)
+		}
+		if iterations == 0 {
+			continue
+		}
+
+		res, err := measure("insert_members", iterations, func(_ int, start func()) error {
+			leaves := make([]rln.IDCommitment, batch)
+			for i := range leaves {
+				// 31 random bytes always fit in a field element
+				random.Read(leaves[i][:31])
+			}
+			index := rln.MembershipIndex(r.LeavesSet())
+			start()
+			return r.InsertMembers(index, leaves)
+		})
+		if err != nil {
+			return err
+		}
+		res.BatchSize = batch
+		if err := emit(res); err != nil {
+			return err
+		}
+	}
+
+	leaves := r.LeavesSet()
+	res, err = measure("get_merkle_proof", opts.iterations, func(_ int, start func()) error {
+		index := rln.MembershipIndex(random.Intn(int(leaves)))
+		start()
+		_, err := r.GetMerkleProof(index)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := emit(res); err != nil {
+		return err
+	}
+
+	signal := []byte("rlnbench")
+	epoch := rln.ToEpoch(1)
+
+	var proof *rln.RateLimitProof
+	res, err = measure("generate_proof", opts.proofIterations, func(int, func()) error {
+		var err error
+		proof, err = r.GenerateProof(signal, *member, 0, epoch)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := emit(res); err != nil {
+		return err
+	}
+
+	res, err = measure("generate_rln_proof_with_witness", opts.proofIterations, func(_ int, start func()) error {
+		merkleProof, err := r.GetMerkleProof(0)
+		if err != nil {
+			return err
+		}
+		witness := rln.CreateWitness(member.IDSecretHash, signal, epoch, merkleProof)
+		start()
+		_, err = r.GenerateRLNProofWithWitness(witness)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := emit(res); err != nil {
+		return err
+	}
+
+	root, err := r.GetMerkleRoot()
+	if err != nil {
+		return err
+	}
+
+	res, err = measure("verify", opts.iterations, func(int, func()) error {
+		verified, err := r.Verify(signal, *proof, root)
+		if err != nil {
+			return err
+		}
+		if !verified {
+			return errors.New("the proof is not valid")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := emit(res); err != nil {
+		return err
+	}
+
+	return r.Flush()
+}
@@ -0,0 +1,87 @@
+// rlncheck verifies that the root of a persistent RLN tree matches its leaves,
+// and optionally rebuilds the tree from them. The node using the tree must be
+// stopped while running it: it fails if the tree is locked by a node that opened
+// it with rln.CreateTree or rln.OpenTree.
+//
+//	rlncheck -path /var/lib/waku/rln_tree.db [-depth 20] [-repair]
+//	rlncheck -config tree.yaml [-repair]
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/waku-org/go-zerokit-rln/rln"
+)
+
+func main() {
+	configPath := flag.String("config", "", "tree configuration file (json, yaml or toml)")
+	treePath := flag.String("path", "", "path of the tree database, used if no configuration file is specified")
+	depth := flag.Int("depth", int(rln.DefaultTreeDepth), "depth of the tree")
+	repair := flag.Bool("repair", false, "rebuild the tree from its leaves if inconsistencies are found")
+	flag.Parse()
+
+	report, err := run(*configPath, *treePath, rln.TreeDepth(*depth), *repair)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+
+	fmt.Printf("leaves:        %d\n", report.Leaves)
+	fmt.Printf("stored root:   %x\n", report.StoredRoot)
+	fmt.Printf("computed root: %x\n", report.ComputedRoot)
+	for _, node := range report.InconsistentNodes {
+		fmt.Printf("inconsistent node at level %d index %d: stored %x, expected %x\n", node.Level, node.Index, node.Stored, node.Expected)
+	}
+	if report.MetadataError != nil {
+		fmt.Println("metadata:", report.MetadataError)
+	}
+
+	switch {
+	case report.Consistent():
+		fmt.Println("tree is consistent")
+	case report.Repaired:
+		fmt.Println("tree was repaired")
+	default:
+		fmt.Println("tree is inconsistent")
+		os.Exit(1)
+	}
+}
+
+func run(configPath string, treePath string, depth rln.TreeDepth, repair bool) (rln.IntegrityReport, error) {
+	var config rln.TreeConfig
+	switch {
+	case configPath != "":
+		var err error
+		config, err = rln.LoadTreeConfig(configPath)
+		if err != nil {
+			return rln.IntegrityReport{}, err
+		}
+	case treePath != "":
+		config = rln.DefaultTreeConfig()
+		config.Path = treePath
+	default:
+		return rln.IntegrityReport{}, errors.New("either -config or -path is required")
+	}
+
+	// The tree must exist, otherwise zerokit creates an empty one
+	if _, err := os.Stat(config.Path); err != nil {
+		return rln.IntegrityReport{}, err
+	}
+
+	// Fails with rln.ErrTreeLocked while a node uses the tree
+	lock, err := rln.LockTree(config.Path)
+	if err != nil {
+		return rln.IntegrityReport{}, err
+	}
+	defer lock.Release()
+
+	r, err := rln.NewWithConfig(depth, &config)
+	if err != nil {
+		return rln.IntegrityReport{}, err
+	}
+
+	return r.CheckIntegrity(rln.IntegrityOptions{Repair: repair})
+}
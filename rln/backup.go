@@ -0,0 +1,271 @@
+package rln
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Names of the files inside a backup directory
+const (
+	backupInfoFile     = "backup.json"
+	backupTreeDir      = "tree"
+	backupManifestFile = "manifest.json"
+	backupRootsFile    = "roots.json"
+)
+
+const backupVersion = 1
+
+// backupInfo describes the state of the tree when the backup was taken
+type backupInfo struct {
+	Version   int       `json:"version"`
+	Depth     TreeDepth `json:"depth"`
+	NextIndex uint      `json:"next_index"`
+	Root      string    `json:"root"`
+	Metadata  string    `json:"metadata"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Backup copies the tree database to `dir`, which must not exist or be empty, together with the
+// tree manifest and root history if they exist. Modifications of the tree are blocked while the
+// copy is done, so it can be used on a live tree. The root, next index and metadata at the time
+// of the backup are recorded, so RestoreBackup can validate the copy
+func (r *RLN) Backup(dir string) error {
+	if r.treePath == "" {
+		return errors.New("the tree is not stored on disk")
+	}
+
+	if err := ensureEmptyDir(dir); err != nil {
+		return err
+	}
+
+	if err := r.lockWrites(); err != nil {
+		return err
+	}
+	defer r.writeMu.Unlock()
+
+	if err := r.Flush(); err != nil {
+		return err
+	}
+
+	root, err := r.GetMerkleRoot()
+	if err != nil {
+		return err
+	}
+
+	// zerokit fails when no metadata has been stored yet
+	metadata, err := r.GetMetadata()
+	if err != nil {
+		metadata = nil
+	}
+
+	if err := copyDir(r.treePath, filepath.Join(dir, backupTreeDir)); err != nil {
+		return fmt.Errorf("could not copy the tree: %w", err)
+	}
+
+	sidecars := map[string]string{
+		treeManifestPath(r.treePath): backupManifestFile,
+		rootHistoryPath(r.treePath):  backupRootsFile,
+	}
+	for src, dst := range sidecars {
+		if err := copyFile(src, filepath.Join(dir, dst)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	b, err := json.Marshal(backupInfo{
+		Version:   backupVersion,
+		Depth:     r.depth,
+		NextIndex: r.LeavesSet(),
+		Root:      hex.EncodeToString(root[:]),
+		Metadata:  hex.EncodeToString(metadata),
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Written last, so an interrupted backup can not be restored
+	return os.WriteFile(filepath.Join(dir, backupInfoFile), b, 0600)
+}
+
+// RestoreBackup copies a backup created with Backup to `treeConfig.Path`, which must not exist,
+// and opens it. The instance is returned only if its root, next index and metadata match the
+// ones recorded in the backup. Otherwise the copy is removed, so the restore can be retried in
+// the same path. Like CreateTree, the tree is locked until Close is called
+func RestoreBackup(dir string, treeConfig TreeConfig) (*RLN, error) {
+	if err := treeConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, backupInfoFile))
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup: %w", err)
+	}
+
+	var info backupInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		return nil, fmt.Errorf("invalid backup: %w", err)
+	}
+
+	if info.Version != backupVersion {
+		return nil, fmt.Errorf("unsupported backup version: %d", info.Version)
+	}
+
+	rootBytes, err := hex.DecodeString(info.Root)
+	if err != nil || len(rootBytes) != len(MerkleNode{}) {
+		return nil, errors.New("invalid backup: wrong root")
+	}
+	var expectedRoot MerkleNode
+	copy(expectedRoot[:], rootBytes)
+
+	expectedMetadata, err := hex.DecodeString(info.Metadata)
+	if err != nil {
+		return nil, errors.New("invalid backup: wrong metadata")
+	}
+
+	lock, err := acquireTreeLock(treeConfig.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// None of the files removed on failure may exist before
+	for _, path := range []string{treeConfig.Path, treeManifestPath(treeConfig.Path), rootHistoryPath(treeConfig.Path)} {
+		if _, err := os.Stat(path); err == nil {
+			lock.release()
+			return nil, fmt.Errorf("%w: %s", ErrTreeExists, path)
+		}
+	}
+
+	r, err := restoreBackupFiles(dir, treeConfig, info.Depth)
+	if err == nil {
+		if err = r.checkBackup(info.NextIndex, expectedRoot, expectedMetadata); err != nil {
+			err = fmt.Errorf("the restored tree does not match the backup: %w", err)
+		}
+	}
+	if err != nil {
+		// zerokit can not free the instance, but once its database is removed a new one can be
+		// created in the same path, so the path is not marked as opened
+		removeRestoredFiles(treeConfig.Path)
+		lock.release()
+		return nil, err
+	}
+
+	markTreeOpened(treeConfig.Path)
+	r.lock = lock
+	return r, nil
+}
+
+// removeRestoredFiles removes the tree and sidecar files copied by a failed restore
+func removeRestoredFiles(treePath string) {
+	os.RemoveAll(treePath)
+	os.Remove(treeManifestPath(treePath))
+	os.Remove(rootHistoryPath(treePath))
+}
+
+func restoreBackupFiles(dir string, treeConfig TreeConfig, depth TreeDepth) (*RLN, error) {
+	if err := copyDir(filepath.Join(dir, backupTreeDir), treeConfig.Path); err != nil {
+		return nil, fmt.Errorf("could not copy the tree: %w", err)
+	}
+
+	sidecars := map[string]string{
+		backupManifestFile: treeManifestPath(treeConfig.Path),
+		backupRootsFile:    rootHistoryPath(treeConfig.Path),
+	}
+	for src, dst := range sidecars {
+		if err := copyFile(filepath.Join(dir, src), dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return NewWithConfig(depth, &treeConfig)
+}
+
+func (r *RLN) checkBackup(nextIndex uint, root MerkleNode, metadata []byte) error {
+	if r.LeavesSet() != nextIndex {
+		return fmt.Errorf("next index is %d, expected %d", r.LeavesSet(), nextIndex)
+	}
+
+	currentRoot, err := r.GetMerkleRoot()
+	if err != nil {
+		return err
+	}
+	if currentRoot != root {
+		return fmt.Errorf("root is %x, expected %x", currentRoot, root)
+	}
+
+	currentMetadata, err := r.GetMetadata()
+	if err != nil {
+		currentMetadata = nil
+	}
+	if string(currentMetadata) != string(metadata) {
+		return errors.New("metadata does not match")
+	}
+
+	return nil
+}
+
+func ensureEmptyDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return os.MkdirAll(dir, 0700)
+	}
+	if err != nil {
+		return err
+	}
+	if len(entries) != 0 {
+		return fmt.Errorf("backup directory %s is not empty", dir)
+	}
+	return nil
+}
+
+func copyDir(src string, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0700)
+		}
+
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,109 @@
+package rln
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+func (s *RLNSuite) TestBackupRestore() {
+	dir := s.T().TempDir()
+
+	rln, err := CreateTree(testTreeConfig(filepath.Join(dir, "tree")), TreeManifest{Depth: DefaultTreeDepth, GroupID: "backup"})
+	s.NoError(err)
+
+	err = rln.EnableRootHistory(10)
+	s.NoError(err)
+
+	for i := 0; i < 5; i++ {
+		rln.SetBlockNumber(uint64(i))
+		err = rln.AtomicOperation(MembershipIndex(i), []IDCommitment{{byte(i + 1)}}, nil)
+		s.NoError(err)
+	}
+
+	err = rln.SetMetadata([]byte("metadata"))
+	s.NoError(err)
+
+	root, err := rln.GetMerkleRoot()
+	s.NoError(err)
+
+	// Backups require a tree stored on disk
+	inMemory, err := NewRLN()
+	s.NoError(err)
+	err = inMemory.Backup(filepath.Join(dir, "in-memory"))
+	s.Error(err)
+
+	backupDir := filepath.Join(dir, "backup")
+	err = rln.Backup(backupDir)
+	s.NoError(err)
+
+	// The directory must be empty
+	err = rln.Backup(backupDir)
+	s.Error(err)
+
+	// Changes after the backup are not included
+	err = rln.InsertMember(IDCommitment{0xff})
+	s.NoError(err)
+
+	restored, err := RestoreBackup(backupDir, testTreeConfig(filepath.Join(dir, "restored")))
+	s.NoError(err)
+
+	restoredRoot, err := restored.GetMerkleRoot()
+	s.NoError(err)
+	s.Equal(root, restoredRoot)
+	s.Equal(uint(5), restored.LeavesSet())
+
+	metadata, err := restored.GetMetadata()
+	s.NoError(err)
+	s.Equal([]byte("metadata"), metadata)
+
+	// Sidecar files are restored as well
+	manifest, err := readTreeManifest(treeManifestPath(filepath.Join(dir, "restored")))
+	s.NoError(err)
+	s.Equal("backup", manifest.GroupID)
+
+	err = restored.EnableRootHistory(10)
+	s.NoError(err)
+	s.True(restored.IsRecentRoot(root))
+
+	s.NoError(restored.Close())
+
+	// The target path must not exist
+	_, err = RestoreBackup(backupDir, testTreeConfig(filepath.Join(dir, "tree")))
+	s.Error(err)
+
+	// A backup whose root does not match the tree
+	infoPath := filepath.Join(backupDir, backupInfoFile)
+	b, err := os.ReadFile(infoPath)
+	s.NoError(err)
+	var info backupInfo
+	s.NoError(json.Unmarshal(b, &info))
+	info.Root = "00" + info.Root[2:]
+	b, err = json.Marshal(info)
+	s.NoError(err)
+	s.NoError(os.WriteFile(infoPath, b, 0600))
+
+	corruptedPath := filepath.Join(dir, "corrupted")
+	_, err = RestoreBackup(backupDir, testTreeConfig(corruptedPath))
+	s.ErrorContains(err, "root")
+
+	// The copy was removed, so the restore can be retried once the backup is fixed
+	s.NoDirExists(corruptedPath)
+	s.NoFileExists(treeManifestPath(corruptedPath))
+	s.NoFileExists(rootHistoryPath(corruptedPath))
+
+	info.Root = hex.EncodeToString(root[:])
+	b, err = json.Marshal(info)
+	s.NoError(err)
+	s.NoError(os.WriteFile(infoPath, b, 0600))
+
+	restored, err = RestoreBackup(backupDir, testTreeConfig(corruptedPath))
+	s.NoError(err)
+	restoredRoot, err = restored.GetMerkleRoot()
+	s.NoError(err)
+	s.Equal(root, restoredRoot)
+	s.NoError(restored.Close())
+
+	s.NoError(rln.Close())
+}
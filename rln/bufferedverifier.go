@@ -0,0 +1,56 @@
+package rln
+
+import (
+	"context"
+	"hash"
+	"sync"
+)
+
+// maxPooledBufferSize is the largest buffer kept for reuse, so a few large signals do not
+// keep the memory allocated
+const maxPooledBufferSize = 64 * 1024
+
+// verifyBuffers contains the input of zerokit's verify_with_roots, and the
+// state used to calculate its VerifyCache key
+type verifyBuffers struct {
+	proof  []byte
+	roots  []byte
+	hash   hash.Hash
+	digest []byte
+}
+
+var verifyBufferPool = sync.Pool{
+	New: func() any {
+		return &verifyBuffers{}
+	},
+}
+
+func (b *verifyBuffers) release() {
+	if cap(b.proof) > maxPooledBufferSize || cap(b.roots) > maxPooledBufferSize {
+		return
+	}
+	verifyBufferPool.Put(b)
+}
+
+// BufferedVerifier verifies proofs reusing its own serialization buffers, instead of the pool
+// shared by all the RLN instances. It is not safe for concurrent use: each goroutine verifying
+// messages should create its own BufferedVerifier
+type BufferedVerifier struct {
+	rln     *RLN
+	buffers verifyBuffers
+}
+
+// NewBufferedVerifier creates a BufferedVerifier that uses this instance
+func (r *RLN) NewBufferedVerifier() *BufferedVerifier {
+	return &BufferedVerifier{rln: r}
+}
+
+// Verify is equivalent to RLN.Verify
+func (v *BufferedVerifier) Verify(data []byte, proof RateLimitProof, roots ...[32]byte) (bool, error) {
+	return v.VerifyContext(context.Background(), data, proof, roots...)
+}
+
+// VerifyContext is equivalent to RLN.VerifyContext
+func (v *BufferedVerifier) VerifyContext(ctx context.Context, data []byte, proof RateLimitProof, roots ...[32]byte) (bool, error) {
+	return v.rln.verify(ctx, &v.buffers, data, &proof, roots)
+}
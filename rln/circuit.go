@@ -0,0 +1,557 @@
+package rln
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fp"
+)
+
+// Names of the circuit resources inside a directory, as in
+// https://github.com/vacp2p/zerokit/tree/v0.3.5/rln/resources/tree_height_20
+const (
+	CircuitWasmFile            = "rln.wasm"
+	CircuitZkeyFile            = "rln_final.zkey"
+	CircuitVerificationKeyFile = "verification_key.json"
+)
+
+// CircuitResources contains the artifacts of the RLN circuit: the witness calculator,
+// the proving key and the verification key
+type CircuitResources struct {
+	Wasm            []byte
+	Zkey            []byte
+	VerificationKey []byte
+}
+
+// CircuitDigests contains the expected SHA-256 digests of the circuit resources.
+// Zero digests are not checked
+type CircuitDigests struct {
+	Wasm            [32]byte
+	Zkey            [32]byte
+	VerificationKey [32]byte
+}
+
+// LoadCircuitResources reads the circuit resources stored in the directory `dir` of `fsys`.
+// Since embed.FS implements fs.FS, resources can be embedded in the binary
+func LoadCircuitResources(fsys fs.FS, dir string) (CircuitResources, error) {
+	var result CircuitResources
+	var err error
+
+	result.Wasm, err = fs.ReadFile(fsys, path.Join(dir, CircuitWasmFile))
+	if err != nil {
+		return CircuitResources{}, err
+	}
+
+	result.Zkey, err = fs.ReadFile(fsys, path.Join(dir, CircuitZkeyFile))
+	if err != nil {
+		return CircuitResources{}, err
+	}
+
+	result.VerificationKey, err = fs.ReadFile(fsys, path.Join(dir, CircuitVerificationKeyFile))
+	if err != nil {
+		return CircuitResources{}, err
+	}
+
+	return result, nil
+}
+
+// ReadCircuitResources reads the circuit resources from readers
+func ReadCircuitResources(wasm io.Reader, zkey io.Reader, verifKey io.Reader) (CircuitResources, error) {
+	var result CircuitResources
+	var err error
+
+	result.Wasm, err = io.ReadAll(wasm)
+	if err != nil {
+		return CircuitResources{}, fmt.Errorf("could not read wasm: %w", err)
+	}
+
+	result.Zkey, err = io.ReadAll(zkey)
+	if err != nil {
+		return CircuitResources{}, fmt.Errorf("could not read zkey: %w", err)
+	}
+
+	result.VerificationKey, err = io.ReadAll(verifKey)
+	if err != nil {
+		return CircuitResources{}, fmt.Errorf("could not read verification key: %w", err)
+	}
+
+	return result, nil
+}
+
+// Validate checks that the resources match the expected digests (if not nil), that
+// the witness calculator was built for a tree of the specified depth, and that the
+// proving and verification keys belong to the same circuit. The verification key must be
+// the one bundled in zerokit for the depth, if any, and the proving key must have been
+// generated for a circuit with as many variables as signals computed by the witness calculator
+func (c CircuitResources) Validate(depth TreeDepth, digests *CircuitDigests) error {
+	if digests != nil {
+		if err := checkDigest("wasm", c.Wasm, digests.Wasm); err != nil {
+			return err
+		}
+		if err := checkDigest("zkey", c.Zkey, digests.Zkey); err != nil {
+			return err
+		}
+		if err := checkDigest("verification key", c.VerificationKey, digests.VerificationKey); err != nil {
+			return err
+		}
+	}
+
+	wasmDepth, err := circuitDepth(c.Wasm)
+	if err != nil {
+		return err
+	}
+
+	if wasmDepth != depth {
+		return fmt.Errorf("circuit was built for a tree of depth %d, expected %d", wasmDepth, depth)
+	}
+
+	vk, err := parseVerifyingKey(c.VerificationKey)
+	if err != nil {
+		return fmt.Errorf("invalid verification key: %w", err)
+	}
+
+	if builtin, err := builtinVerifyingKey(depth); err == nil {
+		builtinVK, err := parseVerifyingKey(builtin)
+		if err != nil {
+			return err
+		}
+		if !vk.equal(builtinVK) {
+			return fmt.Errorf("verification key does not match the circuit for a tree of depth %d", depth)
+		}
+	}
+
+	header, err := checkZkey(c.Zkey, vk)
+	if err != nil {
+		return err
+	}
+
+	witnessSize, err := circuitWitnessSize(c.Wasm)
+	if err != nil {
+		return err
+	}
+
+	if header.nVars != witnessSize {
+		return fmt.Errorf("zkey was generated for a circuit with %d variables, but the wasm computes %d signals", header.nVars, witnessSize)
+	}
+
+	return nil
+}
+
+// NewFromResources validates the circuit resources and uses them to create an RLN instance
+func NewFromResources(depth TreeDepth, resources CircuitResources, treeConfig *TreeConfig, digests *CircuitDigests) (*RLN, error) {
+	if err := resources.Validate(depth, digests); err != nil {
+		return nil, err
+	}
+
+	return NewRLNWithParams(int(depth), resources.Wasm, resources.Zkey, resources.VerificationKey, treeConfig)
+}
+
+// NewFromFS creates an RLN instance with the circuit resources stored in the directory `dir` of `fsys`
+func NewFromFS(depth TreeDepth, fsys fs.FS, dir string, treeConfig *TreeConfig, digests *CircuitDigests) (*RLN, error) {
+	resources, err := LoadCircuitResources(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFromResources(depth, resources, treeConfig, digests)
+}
+
+// NewFromDir creates an RLN instance with the circuit resources stored in the directory `dir`
+func NewFromDir(depth TreeDepth, dir string, treeConfig *TreeConfig, digests *CircuitDigests) (*RLN, error) {
+	return NewFromFS(depth, os.DirFS(dir), ".", treeConfig, digests)
+}
+
+// NewFromReaders creates an RLN instance with circuit resources read from `wasm`, `zkey` and `verifKey`
+func NewFromReaders(depth TreeDepth, wasm io.Reader, zkey io.Reader, verifKey io.Reader, treeConfig *TreeConfig, digests *CircuitDigests) (*RLN, error) {
+	resources, err := ReadCircuitResources(wasm, zkey, verifKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFromResources(depth, resources, treeConfig, digests)
+}
+
+func checkDigest(name string, content []byte, expected [32]byte) error {
+	if expected == ([32]byte{}) {
+		return nil
+	}
+
+	if sha256.Sum256(content) != expected {
+		return fmt.Errorf("%s does not match the expected digest", name)
+	}
+
+	return nil
+}
+
+var wasmHeader = []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+
+// wasmDataSection is the id of the section containing the initial memory
+const wasmDataSection = 11
+
+// pathElementsSignal is the input of the RLN circuit with one element per tree level
+const pathElementsSignal = "path_elements"
+
+// wasmSections splits a wasm module into its sections, indexed by id
+func wasmSections(wasm []byte) (map[byte][]byte, error) {
+	if !bytes.HasPrefix(wasm, wasmHeader) {
+		return nil, errors.New("invalid wasm: wrong header")
+	}
+
+	sections := make(map[byte][]byte)
+	offset := len(wasmHeader)
+	for offset < len(wasm) {
+		sectionID := wasm[offset]
+		size, n := binary.Uvarint(wasm[offset+1:])
+		if n <= 0 || size > uint64(len(wasm)-offset-1-n) {
+			return nil, errors.New("invalid wasm: wrong section size")
+		}
+		offset += 1 + n
+
+		sections[sectionID] = wasm[offset : offset+int(size)]
+		offset += int(size)
+	}
+
+	return sections, nil
+}
+
+// circuitDepth returns the depth of the tree the witness calculator was built for.
+// The wasm generated by circom stores a table with the inputs of the circuit as
+// [ fnv1a64(name)<8> | signal_id<4> | signal_size<4> ] in the data section.
+// The size of path_elements is the depth of the tree
+func circuitDepth(wasm []byte) (TreeDepth, error) {
+	sections, err := wasmSections(wasm)
+	if err != nil {
+		return 0, err
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(pathElementsSignal))
+	signalHash := binary.LittleEndian.AppendUint64(nil, h.Sum64())
+
+	section := sections[wasmDataSection]
+	i := bytes.Index(section, signalHash)
+	if i < 0 || i+16 > len(section) {
+		return 0, fmt.Errorf("invalid wasm: input %s not found", pathElementsSignal)
+	}
+
+	return TreeDepth(binary.LittleEndian.Uint32(section[i+12:])), nil
+}
+
+// Sections of a wasm module used to find the code of an exported function
+const (
+	wasmImportSection = 2
+	wasmExportSection = 7
+	wasmCodeSection   = 10
+)
+
+// Kinds of the imports and exports of a wasm module
+const (
+	wasmFunctionKind = 0
+	wasmTableKind    = 1
+	wasmMemoryKind   = 2
+	wasmGlobalKind   = 3
+)
+
+// Opcodes of the body of the getWitnessSize function
+const (
+	wasmI32Const = 0x41
+	wasmEnd      = 0x0b
+)
+
+// witnessSizeFunction is the function of the witness calculator returning the number of signals of the witness
+const witnessSizeFunction = "getWitnessSize"
+
+// wasmReader decodes the LEB128 integers and vectors of a wasm section. The first error is kept
+type wasmReader struct {
+	b   []byte
+	err error
+}
+
+func (r *wasmReader) byte() byte {
+	if r.err == nil && len(r.b) == 0 {
+		r.err = errors.New("invalid wasm: truncated")
+	}
+	if r.err != nil {
+		return 0
+	}
+	v := r.b[0]
+	r.b = r.b[1:]
+	return v
+}
+
+func (r *wasmReader) uint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = errors.New("invalid wasm: truncated")
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *wasmReader) int() int64 {
+	var result int64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b := r.byte()
+		result |= int64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			if shift+7 < 64 && b&0x40 != 0 {
+				result |= -1 << (shift + 7)
+			}
+			return result
+		}
+	}
+	if r.err == nil {
+		r.err = errors.New("invalid wasm: integer too long")
+	}
+	return 0
+}
+
+func (r *wasmReader) bytes() []byte {
+	n := r.uint()
+	if r.err == nil && n > uint64(len(r.b)) {
+		r.err = errors.New("invalid wasm: truncated")
+	}
+	if r.err != nil {
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *wasmReader) limits() {
+	if flags := r.byte(); flags&1 != 0 {
+		r.uint()
+	}
+	r.uint()
+}
+
+// circuitWitnessSize returns the number of signals of the witness computed by the witness
+// calculator, which is the number of variables of the circuit in its proving key. circom
+// generates getWitnessSize as a function without locals returning a constant:
+// [ num_locals<0> | i32.const | size<sleb128> | end ]
+func circuitWitnessSize(wasm []byte) (uint32, error) {
+	sections, err := wasmSections(wasm)
+	if err != nil {
+		return 0, err
+	}
+
+	// Imported functions come first in the function index space. The import section is optional
+	importedFunctions := uint64(0)
+	imports := wasmReader{b: sections[wasmImportSection]}
+	importCount := uint64(0)
+	if len(imports.b) != 0 {
+		importCount = imports.uint()
+	}
+	for i := uint64(0); i < importCount && imports.err == nil; i++ {
+		imports.bytes() // module
+		imports.bytes() // name
+		switch imports.byte() {
+		case wasmFunctionKind:
+			imports.uint()
+			importedFunctions++
+		case wasmTableKind:
+			imports.byte()
+			imports.limits()
+		case wasmMemoryKind:
+			imports.limits()
+		case wasmGlobalKind:
+			imports.byte()
+			imports.byte()
+		default:
+			return 0, errors.New("invalid wasm: unknown import kind")
+		}
+	}
+	if imports.err != nil {
+		return 0, imports.err
+	}
+
+	exports := wasmReader{b: sections[wasmExportSection]}
+	function, found := uint64(0), false
+	for i, n := uint64(0), exports.uint(); i < n && exports.err == nil && !found; i++ {
+		name := exports.bytes()
+		kind := exports.byte()
+		index := exports.uint()
+		if kind == wasmFunctionKind && string(name) == witnessSizeFunction {
+			function, found = index, true
+		}
+	}
+	if exports.err != nil {
+		return 0, exports.err
+	}
+	if !found || function < importedFunctions {
+		return 0, fmt.Errorf("invalid wasm: function %s not found", witnessSizeFunction)
+	}
+
+	code := wasmReader{b: sections[wasmCodeSection]}
+	var body []byte
+	for i, n := uint64(0), code.uint(); i < n && code.err == nil; i++ {
+		b := code.bytes()
+		if i == function-importedFunctions {
+			body = b
+			break
+		}
+	}
+	if code.err != nil {
+		return 0, code.err
+	}
+
+	r := wasmReader{b: body}
+	locals := r.uint()
+	opcode := r.byte()
+	size := r.int()
+	end := r.byte()
+	if r.err != nil || locals != 0 || opcode != wasmI32Const || end != wasmEnd || size < 0 || size > math.MaxUint32 {
+		return 0, fmt.Errorf("invalid wasm: unexpected %s function", witnessSizeFunction)
+	}
+
+	return uint32(size), nil
+}
+
+// Sections of the zkey format used by snarkjs
+const (
+	zkeyHeaderSection        = 1
+	zkeyGroth16HeaderSection = 2
+	zkeyGroth16Protocol      = 1
+)
+
+// zkeyHeader contains the size of the circuit a zkey was generated for
+type zkeyHeader struct {
+	nVars uint32
+}
+
+// checkZkey verifies that the zkey is a Groth16 proving key for the RLN circuit whose
+// verifying key is `vk`, and returns its header. The groth16 header is serialized as
+// [ n8q<4> | q<n8q> | n8r<4> | r<n8r> | n_vars<4> | n_public<4> | domain_size<4> | alpha_1 | beta_1 | beta_2 | gamma_2 | delta_1 | delta_2 ]
+// where points are stored uncompressed with coordinates in montgomery form
+// See https://github.com/iden3/snarkjs/blob/v0.7.0/src/zkey_utils.js
+func checkZkey(zkey []byte, vk *verifyingKey) (zkeyHeader, error) {
+	sections, err := zkeySections(zkey)
+	if err != nil {
+		return zkeyHeader{}, err
+	}
+
+	header, ok := sections[zkeyHeaderSection]
+	if !ok || len(header) < 4 || binary.LittleEndian.Uint32(header) != zkeyGroth16Protocol {
+		return zkeyHeader{}, errors.New("invalid zkey: not a groth16 proving key")
+	}
+
+	groth16Header, ok := sections[zkeyGroth16HeaderSection]
+	if !ok {
+		return zkeyHeader{}, errors.New("invalid zkey: missing groth16 header")
+	}
+
+	r := bytes.NewReader(groth16Header)
+	readUint32 := func() uint32 {
+		var v uint32
+		if err == nil {
+			err = binary.Read(r, binary.LittleEndian, &v)
+		}
+		return v
+	}
+	readElement := func() fp.Element {
+		// fp.Element uses montgomery form with little endian limbs as well
+		var e fp.Element
+		if err == nil {
+			err = binary.Read(r, binary.LittleEndian, &e)
+		}
+		return e
+	}
+	readG1 := func() bn254.G1Affine {
+		var p bn254.G1Affine
+		p.X = readElement()
+		p.Y = readElement()
+		return p
+	}
+	readG2 := func() bn254.G2Affine {
+		var p bn254.G2Affine
+		p.X.A0 = readElement()
+		p.X.A1 = readElement()
+		p.Y.A0 = readElement()
+		p.Y.A1 = readElement()
+		return p
+	}
+
+	if n8q := readUint32(); err == nil && n8q != fp.Bytes {
+		return zkeyHeader{}, fmt.Errorf("invalid zkey: unsupported field size %d", n8q)
+	}
+	var q [fp.Bytes]byte
+	if err == nil {
+		_, err = io.ReadFull(r, q[:])
+	}
+	if err == nil && !bytes.Equal(q[:], revert(fp.Modulus().FillBytes(make([]byte, fp.Bytes)))) {
+		return zkeyHeader{}, errors.New("invalid zkey: not a bn254 proving key")
+	}
+
+	n8r := readUint32()
+	if err == nil {
+		_, err = r.Seek(int64(n8r), io.SeekCurrent)
+	}
+
+	var result zkeyHeader
+	result.nVars = readUint32()
+	nPublic := readUint32()
+	_ = readUint32() // domain_size
+
+	alpha := readG1()
+	_ = readG1() // beta_1
+	beta := readG2()
+	gamma := readG2()
+	_ = readG1() // delta_1
+	delta := readG2()
+
+	if err != nil {
+		return zkeyHeader{}, fmt.Errorf("invalid zkey: %w", err)
+	}
+
+	if nPublic != rlnPublicInputs {
+		return zkeyHeader{}, fmt.Errorf("invalid zkey: expected %d public inputs, got %d", rlnPublicInputs, nPublic)
+	}
+
+	if !alpha.Equal(&vk.alpha) || !beta.Equal(&vk.beta) || !gamma.Equal(&vk.gamma) || !delta.Equal(&vk.delta) {
+		return zkeyHeader{}, errors.New("zkey does not match the verification key")
+	}
+
+	return result, nil
+}
+
+// zkeySections splits a file in the binary format used by snarkjs:
+// [ magic<4> | version<4> | n_sections<4> | (section_type<4> | section_size<8> | section<section_size>)* ]
+func zkeySections(zkey []byte) (map[uint32][]byte, error) {
+	if len(zkey) < 12 || !bytes.Equal(zkey[:4], []byte("zkey")) {
+		return nil, errors.New("invalid zkey: wrong header")
+	}
+
+	nSections := binary.LittleEndian.Uint32(zkey[8:])
+	sections := make(map[uint32][]byte)
+	offset := uint64(12)
+	for i := uint32(0); i < nSections; i++ {
+		if offset+12 > uint64(len(zkey)) {
+			return nil, errors.New("invalid zkey: truncated")
+		}
+		sectionType := binary.LittleEndian.Uint32(zkey[offset:])
+		size := binary.LittleEndian.Uint64(zkey[offset+4:])
+		offset += 12
+		if size > uint64(len(zkey))-offset {
+			return nil, errors.New("invalid zkey: truncated")
+		}
+		sections[sectionType] = zkey[offset : offset+size]
+		offset += size
+	}
+
+	return sections, nil
+}
@@ -0,0 +1,280 @@
+package rln
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fp"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/stretchr/testify/require"
+)
+
+// testWitnessSize is the number of signals of the witness calculator returned by testWasm
+func testWitnessSize(depth TreeDepth) uint32 {
+	return 5000 + 40*uint32(depth)
+}
+
+// appendSleb128 encodes a positive integer as a signed LEB128
+func appendSleb128(b []byte, v uint32) []byte {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 && c&0x40 == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+// testWasm returns a wasm module whose data section contains the circom input table entry for
+// path_elements, and whose getWitnessSize function returns testWitnessSize
+func testWasm(depth TreeDepth) []byte {
+	h := fnv.New64a()
+	h.Write([]byte(pathElementsSignal))
+
+	var data []byte
+	data = append(data, 1, 0, 0x41, 0, 0x0b) // one active segment at offset 0
+	entry := binary.LittleEndian.AppendUint64(nil, h.Sum64())
+	entry = binary.LittleEndian.AppendUint32(entry, 8)
+	entry = binary.LittleEndian.AppendUint32(entry, uint32(depth))
+	data = binary.AppendUvarint(data, uint64(len(entry)))
+	data = append(data, entry...)
+
+	var exports []byte
+	exports = append(exports, 1)
+	exports = binary.AppendUvarint(exports, uint64(len(witnessSizeFunction)))
+	exports = append(exports, witnessSizeFunction...)
+	exports = append(exports, wasmFunctionKind, 0)
+
+	body := appendSleb128([]byte{0, wasmI32Const}, testWitnessSize(depth))
+	body = append(body, wasmEnd)
+	code := []byte{1}
+	code = binary.AppendUvarint(code, uint64(len(body)))
+	code = append(code, body...)
+
+	wasm := append([]byte(nil), wasmHeader...)
+	for _, section := range []struct {
+		id      byte
+		content []byte
+	}{
+		{wasmExportSection, exports},
+		{wasmCodeSection, code},
+		{wasmDataSection, data},
+	} {
+		wasm = append(wasm, section.id)
+		wasm = binary.AppendUvarint(wasm, uint64(len(section.content)))
+		wasm = append(wasm, section.content...)
+	}
+	return wasm
+}
+
+// testZkey returns a zkey whose groth16 header matches the verifying key, for a circuit with nVars variables
+func testZkey(t *testing.T, verifKey []byte, nVars uint32) []byte {
+	vk, err := parseVerifyingKey(verifKey)
+	require.NoError(t, err)
+
+	var header bytes.Buffer
+	write := func(v interface{}) {
+		require.NoError(t, binary.Write(&header, binary.LittleEndian, v))
+	}
+	write(uint32(fp.Bytes))
+	header.Write(revert(fp.Modulus().FillBytes(make([]byte, fp.Bytes))))
+	write(uint32(fr.Bytes))
+	header.Write(revert(fr.Modulus().FillBytes(make([]byte, fr.Bytes))))
+	write(nVars)
+	write(uint32(rlnPublicInputs))
+	write(uint32(1024))
+	write(vk.alpha)
+	write(bn254.G1Affine{})
+	write(vk.beta)
+	write(vk.gamma)
+	write(bn254.G1Affine{})
+	write(vk.delta)
+
+	var zkey bytes.Buffer
+	zkey.WriteString("zkey")
+	require.NoError(t, binary.Write(&zkey, binary.LittleEndian, []uint32{1, 2}))
+	require.NoError(t, binary.Write(&zkey, binary.LittleEndian, uint32(zkeyHeaderSection)))
+	require.NoError(t, binary.Write(&zkey, binary.LittleEndian, uint64(4)))
+	require.NoError(t, binary.Write(&zkey, binary.LittleEndian, uint32(zkeyGroth16Protocol)))
+	require.NoError(t, binary.Write(&zkey, binary.LittleEndian, uint32(zkeyGroth16HeaderSection)))
+	require.NoError(t, binary.Write(&zkey, binary.LittleEndian, uint64(header.Len())))
+	zkey.Write(header.Bytes())
+
+	return zkey.Bytes()
+}
+
+func testCircuitResources(t *testing.T, depth TreeDepth) CircuitResources {
+	verifKey, err := builtinVerifyingKey(depth)
+	require.NoError(t, err)
+
+	return CircuitResources{
+		Wasm:            testWasm(depth),
+		Zkey:            testZkey(t, verifKey, testWitnessSize(depth)),
+		VerificationKey: verifKey,
+	}
+}
+
+func TestCircuitDepth(t *testing.T) {
+	depth, err := circuitDepth(testWasm(15))
+	require.NoError(t, err)
+	require.Equal(t, TreeDepth(15), depth)
+
+	_, err = circuitDepth([]byte("not a wasm module"))
+	require.Error(t, err)
+
+	// Valid module without the input table
+	_, err = circuitDepth(wasmHeader)
+	require.Error(t, err)
+
+	// Truncated section
+	wasm := testWasm(20)
+	_, err = circuitDepth(wasm[:len(wasm)-4])
+	require.Error(t, err)
+}
+
+func TestCircuitResourcesValidate(t *testing.T) {
+	resources := testCircuitResources(t, 20)
+	require.NoError(t, resources.Validate(20, nil))
+
+	// Depth mismatch
+	require.Error(t, resources.Validate(19, nil))
+
+	// Pinned digests
+	digests := &CircuitDigests{
+		Wasm:            sha256.Sum256(resources.Wasm),
+		Zkey:            sha256.Sum256(resources.Zkey),
+		VerificationKey: sha256.Sum256(resources.VerificationKey),
+	}
+	require.NoError(t, resources.Validate(20, digests))
+	require.NoError(t, resources.Validate(20, &CircuitDigests{Zkey: digests.Zkey}))
+
+	digests.VerificationKey[0] ^= 0xff
+	require.Error(t, resources.Validate(20, digests))
+
+	// Proving key from a different circuit
+	otherVerifKey, err := builtinVerifyingKey(15)
+	require.NoError(t, err)
+	mismatched := resources
+	mismatched.Zkey = testZkey(t, otherVerifKey, testWitnessSize(20))
+	require.Error(t, mismatched.Validate(20, nil))
+
+	// Invalid zkey
+	mismatched.Zkey = []byte("zkey")
+	require.Error(t, mismatched.Validate(20, nil))
+}
+
+func TestCircuitResourcesValidateMixedDepths(t *testing.T) {
+	// Keys of the circuit of depth 19, consistent between them, with the wasm of depth 20
+	verifKey19, err := builtinVerifyingKey(19)
+	require.NoError(t, err)
+	mixed := testCircuitResources(t, 20)
+	mixed.VerificationKey = verifKey19
+	mixed.Zkey = testZkey(t, verifKey19, testWitnessSize(19))
+	require.Error(t, mixed.Validate(20, nil))
+
+	// Without a bundled key for the depth, the proving key is compared with the wasm
+	custom := CircuitResources{
+		Wasm:            testWasm(16),
+		Zkey:            testZkey(t, verifKey19, testWitnessSize(16)),
+		VerificationKey: verifKey19,
+	}
+	require.NoError(t, custom.Validate(16, nil))
+
+	custom.Zkey = testZkey(t, verifKey19, testWitnessSize(19))
+	require.Error(t, custom.Validate(16, nil))
+}
+
+func TestCircuitWitnessSize(t *testing.T) {
+	size, err := circuitWitnessSize(testWasm(20))
+	require.NoError(t, err)
+	require.Equal(t, testWitnessSize(20), size)
+
+	// Valid module without the function
+	_, err = circuitWitnessSize(wasmHeader)
+	require.Error(t, err)
+}
+
+func TestNewFromFSValidation(t *testing.T) {
+	resources := testCircuitResources(t, 15)
+	fsys := fstest.MapFS{
+		"circuit/" + CircuitWasmFile:            {Data: resources.Wasm},
+		"circuit/" + CircuitZkeyFile:            {Data: resources.Zkey},
+		"circuit/" + CircuitVerificationKeyFile: {Data: resources.VerificationKey},
+	}
+
+	loaded, err := LoadCircuitResources(fsys, "circuit")
+	require.NoError(t, err)
+	require.Equal(t, resources, loaded)
+
+	_, err = NewFromFS(20, fsys, "circuit", nil, nil)
+	require.Error(t, err)
+
+	_, err = NewFromFS(15, fsys, "missing", nil, nil)
+	require.Error(t, err)
+
+	_, err = NewFromReaders(20, bytes.NewReader(resources.Wasm), bytes.NewReader(resources.Zkey), bytes.NewReader(resources.VerificationKey), nil, nil)
+	require.Error(t, err)
+
+	_, err = NewFromDir(20, t.TempDir(), nil, nil)
+	require.Error(t, err)
+}
+
+func (s *RLNSuite) TestRealCircuitResources() {
+	dir := filepath.Join("testdata", "tree_height_20")
+
+	resources, err := LoadCircuitResources(os.DirFS(dir), ".")
+	s.NoError(err)
+
+	depth, err := circuitDepth(resources.Wasm)
+	s.NoError(err)
+	s.Equal(TreeDepth20, depth)
+
+	vk, err := parseVerifyingKey(resources.VerificationKey)
+	s.NoError(err)
+	header, err := checkZkey(resources.Zkey, vk)
+	s.NoError(err)
+
+	witnessSize, err := circuitWitnessSize(resources.Wasm)
+	s.NoError(err)
+	s.Equal(header.nVars, witnessSize)
+
+	s.NoError(resources.Validate(TreeDepth20, &CircuitDigests{
+		Wasm:            sha256.Sum256(resources.Wasm),
+		Zkey:            sha256.Sum256(resources.Zkey),
+		VerificationKey: sha256.Sum256(resources.VerificationKey),
+	}))
+	s.Error(resources.Validate(TreeDepth19, nil))
+
+	// Proving and verification keys of the circuit of depth 19 with the witness calculator
+	// of depth 20
+	verifKey19, err := builtinVerifyingKey(TreeDepth19)
+	s.NoError(err)
+	mixed := resources
+	mixed.VerificationKey = verifKey19
+	s.Error(mixed.Validate(TreeDepth20, nil))
+
+	rln, err := NewFromDir(TreeDepth20, dir, nil, nil)
+	s.NoError(err)
+
+	memKeys, err := rln.MembershipKeyGen()
+	s.NoError(err)
+	err = rln.InsertMember(memKeys.IDCommitment)
+	s.NoError(err)
+	root, err := rln.GetMerkleRoot()
+	s.NoError(err)
+
+	proof, err := rln.GenerateProof([]byte("Hello"), *memKeys, MembershipIndex(0), ToEpoch(1000))
+	s.NoError(err)
+
+	verified, err := rln.Verify([]byte("Hello"), *proof, root)
+	s.NoError(err)
+	s.True(verified)
+}
@@ -0,0 +1,176 @@
+package rln
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Smallest cache capacity accepted by sled. Lower values abort the process
+const minCacheCapacity = 256
+
+// DefaultTreeConfig returns the recommended configuration for a tree stored on disk:
+// a cache of 15000 entries, HighThroughput mode, no compression and a flush every 500ms.
+// The Path must be set before using it
+func DefaultTreeConfig() TreeConfig {
+	return TreeConfig{
+		CacheCapacity: 15000,
+		Mode:          HighThroughput,
+		Compression:   false,
+		FlushInterval: 500 * time.Millisecond,
+	}
+}
+
+// Validate checks the configuration before sending it to zerokit, which otherwise fails
+// without indicating the reason, or aborts the process
+func (t TreeConfig) Validate() error {
+	if t.CacheCapacity < minCacheCapacity {
+		return fmt.Errorf("invalid tree config: cache capacity must be at least %d", minCacheCapacity)
+	}
+
+	if t.Mode != HighThroughput && t.Mode != LowSpace {
+		return fmt.Errorf("invalid tree config: unknown mode %q", t.Mode)
+	}
+
+	if t.FlushInterval <= 0 {
+		return errors.New("invalid tree config: flush interval must be positive")
+	}
+
+	if t.FlushInterval%time.Millisecond != 0 {
+		return fmt.Errorf("invalid tree config: flush interval %s is not a whole number of milliseconds", t.FlushInterval)
+	}
+
+	if t.Path == "" {
+		return errors.New("invalid tree config: path is required")
+	}
+
+	return nil
+}
+
+// treeConfigFile contains the fields of a TreeConfig present in a file. It uses the same
+// names as the zerokit configuration
+type treeConfigFile struct {
+	CacheCapacity *int      `json:"cache_capacity" yaml:"cache_capacity" toml:"cache_capacity"`
+	Mode          *TreeMode `json:"mode" yaml:"mode" toml:"mode"`
+	Compression   *bool     `json:"compression" yaml:"compression" toml:"compression"`
+	FlushInterval *uint     `json:"flush_every_ms" yaml:"flush_every_ms" toml:"flush_every_ms"`
+	Path          *string   `json:"path" yaml:"path" toml:"path"`
+}
+
+func (f treeConfigFile) apply(t *TreeConfig) {
+	if f.CacheCapacity != nil {
+		t.CacheCapacity = *f.CacheCapacity
+	}
+	if f.Mode != nil {
+		t.Mode = *f.Mode
+	}
+	if f.Compression != nil {
+		t.Compression = *f.Compression
+	}
+	if f.FlushInterval != nil {
+		t.FlushInterval = time.Duration(*f.FlushInterval) * time.Millisecond
+	}
+	if f.Path != nil {
+		t.Path = *f.Path
+	}
+}
+
+// UnmarshalJSON reads the format produced by MarshalJSON. Fields missing in the input keep their value
+func (t *TreeConfig) UnmarshalJSON(b []byte) error {
+	var f treeConfigFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	f.apply(t)
+	return nil
+}
+
+// LoadTreeConfig reads a tree configuration from a JSON, YAML or TOML file, depending on its
+// extension. The keys are the ones used by zerokit: cache_capacity, mode, compression,
+// flush_every_ms and path. Missing keys take the value of DefaultTreeConfig. A relative path
+// is resolved from the directory containing the file
+func LoadTreeConfig(path string) (TreeConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return TreeConfig{}, err
+	}
+
+	var f treeConfigFile
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.Unmarshal(b, &f)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &f)
+	case ".toml":
+		err = toml.Unmarshal(b, &f)
+	default:
+		return TreeConfig{}, fmt.Errorf("unsupported tree config format %q", ext)
+	}
+	if err != nil {
+		return TreeConfig{}, fmt.Errorf("could not parse tree config: %w", err)
+	}
+
+	result := DefaultTreeConfig()
+	f.apply(&result)
+
+	if result.Path != "" && !filepath.IsAbs(result.Path) {
+		result.Path = filepath.Join(filepath.Dir(path), result.Path)
+	}
+
+	if err := result.Validate(); err != nil {
+		return TreeConfig{}, err
+	}
+
+	return result, nil
+}
+
+// ApplyEnv overrides the configuration with the environment variables <prefix>_CACHE_CAPACITY,
+// <prefix>_MODE, <prefix>_COMPRESSION, <prefix>_FLUSH_EVERY_MS and <prefix>_PATH that are set
+func (t *TreeConfig) ApplyEnv(prefix string) error {
+	var f treeConfigFile
+
+	if v, ok := os.LookupEnv(prefix + "_CACHE_CAPACITY"); ok {
+		cacheCapacity, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s_CACHE_CAPACITY: %w", prefix, err)
+		}
+		f.CacheCapacity = &cacheCapacity
+	}
+
+	if v, ok := os.LookupEnv(prefix + "_MODE"); ok {
+		mode := TreeMode(v)
+		f.Mode = &mode
+	}
+
+	if v, ok := os.LookupEnv(prefix + "_COMPRESSION"); ok {
+		compression, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s_COMPRESSION: %w", prefix, err)
+		}
+		f.Compression = &compression
+	}
+
+	if v, ok := os.LookupEnv(prefix + "_FLUSH_EVERY_MS"); ok {
+		flushInterval, err := strconv.ParseUint(v, 10, 0)
+		if err != nil {
+			return fmt.Errorf("invalid %s_FLUSH_EVERY_MS: %w", prefix, err)
+		}
+		ms := uint(flushInterval)
+		f.FlushInterval = &ms
+	}
+
+	if v, ok := os.LookupEnv(prefix + "_PATH"); ok {
+		f.Path = &v
+	}
+
+	f.apply(t)
+	return nil
+}
@@ -0,0 +1,133 @@
+package rln
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeConfigValidate(t *testing.T) {
+	config := DefaultTreeConfig()
+	require.Error(t, config.Validate())
+
+	config.Path = "/tmp/rln"
+	require.NoError(t, config.Validate())
+
+	invalid := config
+	invalid.CacheCapacity = 0
+	require.Error(t, invalid.Validate())
+
+	invalid = config
+	invalid.Mode = ""
+	require.Error(t, invalid.Validate())
+
+	invalid = config
+	invalid.FlushInterval = 0
+	require.Error(t, invalid.Validate())
+
+	invalid = config
+	invalid.FlushInterval = 1500 * time.Microsecond
+	require.Error(t, invalid.Validate())
+
+	_, err := NewWithConfig(DefaultTreeDepth, &invalid)
+	require.Error(t, err)
+
+	_, err = NewRLNWithParams(int(DefaultTreeDepth), nil, nil, nil, &invalid)
+	require.Error(t, err)
+}
+
+func TestTreeConfigJSON(t *testing.T) {
+	config := DefaultTreeConfig()
+	config.Mode = LowSpace
+	config.Compression = true
+	config.Path = "/tmp/rln"
+
+	b, err := json.Marshal(config)
+	require.NoError(t, err)
+
+	var decoded TreeConfig
+	err = json.Unmarshal(b, &decoded)
+	require.NoError(t, err)
+	require.Equal(t, config, decoded)
+}
+
+func TestLoadTreeConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"config.json": `{"cache_capacity": 1000, "mode": "LowSpace", "flush_every_ms": 100, "path": "tree"}`,
+		"config.yaml": "cache_capacity: 1000\nmode: LowSpace\nflush_every_ms: 100\npath: tree\n",
+		"config.toml": "cache_capacity = 1000\nmode = \"LowSpace\"\nflush_every_ms = 100\npath = \"tree\"\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+		config, err := LoadTreeConfig(path)
+		require.NoError(t, err, name)
+		require.Equal(t, TreeConfig{
+			CacheCapacity: 1000,
+			Mode:          LowSpace,
+			Compression:   false,
+			FlushInterval: 100 * time.Millisecond,
+			Path:          filepath.Join(dir, "tree"),
+		}, config, name)
+	}
+
+	// Missing keys take the default value
+	path := filepath.Join(dir, "partial.yml")
+	require.NoError(t, os.WriteFile(path, []byte("path: /var/lib/rln\n"), 0600))
+	config, err := LoadTreeConfig(path)
+	require.NoError(t, err)
+	expected := DefaultTreeConfig()
+	expected.Path = "/var/lib/rln"
+	require.Equal(t, expected, config)
+
+	// Invalid values
+	path = filepath.Join(dir, "invalid.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"cache_capacity": 10, "path": "tree"}`), 0600))
+	_, err = LoadTreeConfig(path)
+	require.Error(t, err)
+
+	path = filepath.Join(dir, "config.ini")
+	require.NoError(t, os.WriteFile(path, []byte("path=tree"), 0600))
+	_, err = LoadTreeConfig(path)
+	require.Error(t, err)
+
+	_, err = LoadTreeConfig(filepath.Join(dir, "missing.json"))
+	require.Error(t, err)
+}
+
+func TestTreeConfigApplyEnv(t *testing.T) {
+	t.Setenv("RLN_TREE_CACHE_CAPACITY", "2000")
+	t.Setenv("RLN_TREE_MODE", "LowSpace")
+	t.Setenv("RLN_TREE_COMPRESSION", "true")
+	t.Setenv("RLN_TREE_FLUSH_EVERY_MS", "250")
+	t.Setenv("RLN_TREE_PATH", "/var/lib/rln")
+
+	config := DefaultTreeConfig()
+	err := config.ApplyEnv("RLN_TREE")
+	require.NoError(t, err)
+	require.Equal(t, TreeConfig{
+		CacheCapacity: 2000,
+		Mode:          LowSpace,
+		Compression:   true,
+		FlushInterval: 250 * time.Millisecond,
+		Path:          "/var/lib/rln",
+	}, config)
+
+	// Variables that are not set keep the current value
+	config = DefaultTreeConfig()
+	err = config.ApplyEnv("OTHER_PREFIX")
+	require.NoError(t, err)
+	require.Equal(t, DefaultTreeConfig(), config)
+
+	t.Setenv("RLN_TREE_CACHE_CAPACITY", "many")
+	err = config.ApplyEnv("RLN_TREE")
+	require.Error(t, err)
+}
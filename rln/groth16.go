@@ -0,0 +1,275 @@
+package rln
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fp"
+)
+
+// Flags stored by arkworks in the most significant bits of a compressed point
+const (
+	arkworksYIsNegative     = 1 << 7
+	arkworksPointAtInfinity = 1 << 6
+	arkworksFlagsMask       = arkworksYIsNegative | arkworksPointAtInfinity
+)
+
+// Number of public inputs of the RLN circuit: y, root, nullifier, x, epoch, rln_identifier
+const rlnPublicInputs = 6
+
+// verifyingKey is a Groth16 verifying key for the RLN circuit
+type verifyingKey struct {
+	alpha bn254.G1Affine
+	beta  bn254.G2Affine
+	gamma bn254.G2Affine
+	delta bn254.G2Affine
+	ic    []bn254.G1Affine
+}
+
+// verifyingKeyJSON is the verification_key.json format produced by snarkjs and used by zerokit
+type verifyingKeyJSON struct {
+	Protocol string     `json:"protocol"`
+	Alpha    []string   `json:"vk_alpha_1"`
+	Beta     [][]string `json:"vk_beta_2"`
+	Gamma    [][]string `json:"vk_gamma_2"`
+	Delta    [][]string `json:"vk_delta_2"`
+	IC       [][]string `json:"IC"`
+}
+
+func parseG1(coords []string) (bn254.G1Affine, error) {
+	var p bn254.G1Affine
+	if len(coords) < 2 {
+		return p, errors.New("invalid G1 point")
+	}
+	if _, err := p.X.SetString(coords[0]); err != nil {
+		return p, err
+	}
+	if _, err := p.Y.SetString(coords[1]); err != nil {
+		return p, err
+	}
+	if !p.IsOnCurve() {
+		return p, errors.New("G1 point is not on curve")
+	}
+	return p, nil
+}
+
+func parseG2(coords [][]string) (bn254.G2Affine, error) {
+	var p bn254.G2Affine
+	if len(coords) < 2 || len(coords[0]) != 2 || len(coords[1]) != 2 {
+		return p, errors.New("invalid G2 point")
+	}
+	if _, err := p.X.A0.SetString(coords[0][0]); err != nil {
+		return p, err
+	}
+	if _, err := p.X.A1.SetString(coords[0][1]); err != nil {
+		return p, err
+	}
+	if _, err := p.Y.A0.SetString(coords[1][0]); err != nil {
+		return p, err
+	}
+	if _, err := p.Y.A1.SetString(coords[1][1]); err != nil {
+		return p, err
+	}
+	if !p.IsOnCurve() || !p.IsInSubGroup() {
+		return p, errors.New("G2 point is not in the subgroup")
+	}
+	return p, nil
+}
+
+// parseVerifyingKey parses a verifying key in the verification_key.json format
+func parseVerifyingKey(b []byte) (*verifyingKey, error) {
+	var raw verifyingKeyJSON
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("could not decode verifying key: %w", err)
+	}
+
+	if raw.Protocol != "groth16" {
+		return nil, fmt.Errorf("unsupported verifying key protocol: %s", raw.Protocol)
+	}
+
+	if len(raw.IC) != rlnPublicInputs+1 {
+		return nil, fmt.Errorf("verifying key does not match the RLN circuit: expected %d public inputs, got %d", rlnPublicInputs, len(raw.IC)-1)
+	}
+
+	vk := &verifyingKey{}
+	var err error
+
+	if vk.alpha, err = parseG1(raw.Alpha); err != nil {
+		return nil, fmt.Errorf("invalid vk_alpha_1: %w", err)
+	}
+	if vk.beta, err = parseG2(raw.Beta); err != nil {
+		return nil, fmt.Errorf("invalid vk_beta_2: %w", err)
+	}
+	if vk.gamma, err = parseG2(raw.Gamma); err != nil {
+		return nil, fmt.Errorf("invalid vk_gamma_2: %w", err)
+	}
+	if vk.delta, err = parseG2(raw.Delta); err != nil {
+		return nil, fmt.Errorf("invalid vk_delta_2: %w", err)
+	}
+
+	vk.ic = make([]bn254.G1Affine, len(raw.IC))
+	for i, ic := range raw.IC {
+		if vk.ic[i], err = parseG1(ic); err != nil {
+			return nil, fmt.Errorf("invalid IC[%d]: %w", i, err)
+		}
+	}
+
+	return vk, nil
+}
+
+// equal indicates whether both keys are the same
+func (vk *verifyingKey) equal(other *verifyingKey) bool {
+	if !vk.alpha.Equal(&other.alpha) || !vk.beta.Equal(&other.beta) || !vk.gamma.Equal(&other.gamma) ||
+		!vk.delta.Equal(&other.delta) || len(vk.ic) != len(other.ic) {
+		return false
+	}
+	for i := range vk.ic {
+		if !vk.ic[i].Equal(&other.ic[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// g2TwistB is the b coefficient of the twisted curve y^2 = x^3 + b where G2 lives
+var g2TwistB = func() bn254.E2 {
+	_, _, _, g2 := bn254.Generators()
+	var b, x3 bn254.E2
+	b.Square(&g2.Y)
+	x3.Square(&g2.X).Mul(&x3, &g2.X)
+	return *b.Sub(&b, &x3)
+}()
+
+// decompressG1 decodes a G1 point compressed by arkworks: the x coordinate
+// in little endian with the flags stored in the most significant byte
+func decompressG1(b []byte) (bn254.G1Affine, error) {
+	var p bn254.G1Affine
+
+	x := make([]byte, len(b))
+	copy(x, b)
+	x = revert(x)
+	flags := x[0] & arkworksFlagsMask
+	x[0] &^= arkworksFlagsMask
+
+	// Like arkworks, the point at infinity has no sign
+	if flags == arkworksFlagsMask {
+		return p, errors.New("invalid point flags")
+	}
+	if flags&arkworksPointAtInfinity != 0 {
+		return p, nil
+	}
+
+	if err := p.X.SetBytesCanonical(x); err != nil {
+		return p, err
+	}
+
+	var y2, three fp.Element
+	three.SetUint64(3)
+	y2.Square(&p.X).Mul(&y2, &p.X).Add(&y2, &three)
+	if p.Y.Sqrt(&y2) == nil {
+		return p, errors.New("G1 point is not on curve")
+	}
+
+	if p.Y.LexicographicallyLargest() != (flags&arkworksYIsNegative != 0) {
+		p.Y.Neg(&p.Y)
+	}
+
+	return p, nil
+}
+
+// decompressG2 decodes a G2 point compressed by arkworks: the two components
+// of the x coordinate in little endian with the flags stored in the most
+// significant byte of the second one
+func decompressG2(b []byte) (bn254.G2Affine, error) {
+	var p bn254.G2Affine
+
+	c0 := make([]byte, 32)
+	c1 := make([]byte, 32)
+	copy(c0, b[:32])
+	copy(c1, b[32:64])
+	c0 = revert(c0)
+	c1 = revert(c1)
+	flags := c1[0] & arkworksFlagsMask
+	c1[0] &^= arkworksFlagsMask
+
+	// Like arkworks, the point at infinity has no sign
+	if flags == arkworksFlagsMask {
+		return p, errors.New("invalid point flags")
+	}
+	if flags&arkworksPointAtInfinity != 0 {
+		return p, nil
+	}
+
+	if err := p.X.A0.SetBytesCanonical(c0); err != nil {
+		return p, err
+	}
+	if err := p.X.A1.SetBytesCanonical(c1); err != nil {
+		return p, err
+	}
+
+	var y2 bn254.E2
+	y2.Square(&p.X).Mul(&y2, &p.X).Add(&y2, &g2TwistB)
+	if y2.Legendre() == -1 {
+		return p, errors.New("G2 point is not on curve")
+	}
+	p.Y.Sqrt(&y2)
+
+	if p.Y.LexicographicallyLargest() != (flags&arkworksYIsNegative != 0) {
+		p.Y.Neg(&p.Y)
+	}
+
+	if !p.IsInSubGroup() {
+		return p, errors.New("G2 point is not in the subgroup")
+	}
+
+	return p, nil
+}
+
+// verify checks the zkSNARK of a RateLimitProof against its public inputs. It does not
+// check the signal nor the root: the caller is responsible for those checks
+// Equivalent to: https://github.com/vacp2p/zerokit/blob/v0.3.5/rln/src/protocol.rs (verify_proof)
+func (vk *verifyingKey) verify(proof RateLimitProof) (bool, error) {
+	a, err := decompressG1(proof.Proof[0:32])
+	if err != nil {
+		return false, fmt.Errorf("invalid proof: %w", err)
+	}
+
+	b, err := decompressG2(proof.Proof[32:96])
+	if err != nil {
+		return false, fmt.Errorf("invalid proof: %w", err)
+	}
+
+	c, err := decompressG1(proof.Proof[96:128])
+	if err != nil {
+		return false, fmt.Errorf("invalid proof: %w", err)
+	}
+
+	publicInputs := [rlnPublicInputs][32]byte{
+		proof.ShareY,
+		proof.MerkleRoot,
+		proof.Nullifier,
+		proof.ShareX,
+		proof.Epoch,
+		proof.RLNIdentifier,
+	}
+
+	// vk_x = IC[0] + sum(input_i * IC[i+1])
+	var vkX, term bn254.G1Jac
+	vkX.FromAffine(&vk.ic[0])
+	for i, input := range publicInputs {
+		term.ScalarMultiplicationAffine(&vk.ic[i+1], toFieldElement(input))
+		vkX.AddAssign(&term)
+	}
+
+	var vkXAff, negA bn254.G1Affine
+	vkXAff.FromJacobian(&vkX)
+	negA.Neg(&a)
+
+	// e(A, B) == e(alpha, beta) * e(vk_x, gamma) * e(C, delta)
+	return bn254.PairingCheck(
+		[]bn254.G1Affine{negA, vk.alpha, vkXAff, c},
+		[]bn254.G2Affine{b, vk.beta, vk.gamma, vk.delta},
+	)
+}
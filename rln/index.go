@@ -0,0 +1,123 @@
+package rln
+
+import (
+	"fmt"
+	"sync"
+)
+
+// memberIndex maps the commitments stored in the tree to their position
+type memberIndex struct {
+	mu           sync.RWMutex
+	byCommitment map[IDCommitment]MembershipIndex
+	byIndex      map[MembershipIndex]IDCommitment
+}
+
+func newMemberIndex() *memberIndex {
+	return &memberIndex{
+		byCommitment: make(map[IDCommitment]MembershipIndex),
+		byIndex:      make(map[MembershipIndex]IDCommitment),
+	}
+}
+
+// set stores the commitment found at index. A zero commitment removes the entry
+func (m *memberIndex) set(index MembershipIndex, idComm IDCommitment) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unsafeRemove(index)
+	if idComm == (IDCommitment{}) {
+		return
+	}
+	m.byIndex[index] = idComm
+	m.byCommitment[idComm] = index
+}
+
+func (m *memberIndex) remove(index MembershipIndex) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unsafeRemove(index)
+}
+
+func (m *memberIndex) unsafeRemove(index MembershipIndex) {
+	idComm, ok := m.byIndex[index]
+	if !ok {
+		return
+	}
+	delete(m.byIndex, index)
+	// The same commitment could have been inserted again at a different index
+	if m.byCommitment[idComm] == index {
+		delete(m.byCommitment, idComm)
+	}
+}
+
+func (m *memberIndex) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.byCommitment = make(map[IDCommitment]MembershipIndex)
+	m.byIndex = make(map[MembershipIndex]IDCommitment)
+}
+
+func (m *memberIndex) lookup(idComm IDCommitment) (MembershipIndex, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	index, ok := m.byCommitment[idComm]
+	return index, ok
+}
+
+// EnableIndex builds a map from IDCommitment to MembershipIndex with the leaves currently
+// stored in the tree, and keeps it updated on every insertion and deletion done through
+// this instance. Calling it again rebuilds the map, i.e. after the tree was modified by
+// another process sharing the same database. The tree cannot be modified while the map is
+// built, so no insertion is missed
+func (r *RLN) EnableIndex() error {
+	if err := r.loadTree(); err != nil {
+		return err
+	}
+
+	if err := r.lockWrites(); err != nil {
+		return err
+	}
+	defer r.writeMu.Unlock()
+
+	index := newMemberIndex()
+
+	it := r.Leaves(0)
+	for it.Next() {
+		index.set(it.Index(), it.Leaf())
+	}
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("could not build the member index: %w", err)
+	}
+
+	r.memberIndex.Store(index)
+	return nil
+}
+
+// IndexOf returns the index of the IDCommitment in the tree. It requires EnableIndex to have
+// been called before, otherwise the commitment is never found. If the commitment was inserted
+// more than once, the index of the most recent insertion is returned
+func (r *RLN) IndexOf(idComm IDCommitment) (MembershipIndex, bool) {
+	members := r.memberIndex.Load()
+	if members == nil {
+		return 0, false
+	}
+	return members.lookup(idComm)
+}
+
+// syncIndex reads the leaves at the specified indices from the tree and updates the member index.
+// It must be called with writeMu held
+func (r *RLN) syncIndex(indices ...MembershipIndex) error {
+	members := r.memberIndex.Load()
+	if members == nil {
+		return nil
+	}
+
+	for _, index := range indices {
+		leaf, err := r.GetLeaf(index)
+		if err != nil {
+			return fmt.Errorf("could not update the member index: %w", err)
+		}
+		members.set(index, leaf)
+	}
+
+	return nil
+}
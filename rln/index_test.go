@@ -0,0 +1,110 @@
+package rln
+
+func (s *RLNSuite) TestMemberIndex() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	var commitments []IDCommitment
+	for i := 0; i < 8; i++ {
+		keypair, err := rln.MembershipKeyGen()
+		s.NoError(err)
+		commitments = append(commitments, keypair.IDCommitment)
+	}
+
+	err = rln.InsertMembers(0, commitments[:3])
+	s.NoError(err)
+
+	// Not enabled
+	_, ok := rln.IndexOf(commitments[1])
+	s.False(ok)
+
+	// Built from the existing tree
+	err = rln.EnableIndex()
+	s.NoError(err)
+
+	index, ok := rln.IndexOf(commitments[1])
+	s.True(ok)
+	s.Equal(MembershipIndex(1), index)
+
+	err = rln.InsertMember(commitments[3])
+	s.NoError(err)
+	index, ok = rln.IndexOf(commitments[3])
+	s.True(ok)
+	s.Equal(MembershipIndex(3), index)
+
+	err = rln.InsertMemberAt(10, commitments[4])
+	s.NoError(err)
+	index, ok = rln.IndexOf(commitments[4])
+	s.True(ok)
+	s.Equal(MembershipIndex(10), index)
+
+	err = rln.DeleteMember(0)
+	s.NoError(err)
+	_, ok = rln.IndexOf(commitments[0])
+	s.False(ok)
+
+	err = rln.AtomicOperation(11, commitments[5:7], []MembershipIndex{1})
+	s.NoError(err)
+	_, ok = rln.IndexOf(commitments[1])
+	s.False(ok)
+	index, ok = rln.IndexOf(commitments[6])
+	s.True(ok)
+	s.Equal(MembershipIndex(12), index)
+
+	err = rln.DeleteMembers([]MembershipIndex{2})
+	s.NoError(err)
+	_, ok = rln.IndexOf(commitments[2])
+	s.False(ok)
+
+	// The index matches the one built from scratch
+	rebuilt := newMemberIndex()
+	it := rln.Leaves(0)
+	for it.Next() {
+		rebuilt.set(it.Index(), it.Leaf())
+	}
+	s.NoError(it.Err())
+	s.Equal(rebuilt.byCommitment, rln.memberIndex.Load().byCommitment)
+
+	// Reinitializing the tree replaces the index content
+	err = rln.InitTreeWithMembers(commitments[7:])
+	s.NoError(err)
+	_, ok = rln.IndexOf(commitments[3])
+	s.False(ok)
+	index, ok = rln.IndexOf(commitments[7])
+	s.True(ok)
+	s.Equal(MembershipIndex(0), index)
+}
+
+func (s *RLNSuite) TestMemberIndexConcurrentRebuild() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	commitments := make([]IDCommitment, 50)
+	for i := range commitments {
+		commitments[i] = IDCommitment{byte(i + 1)}
+	}
+
+	done := make(chan error)
+	go func() {
+		for _, idComm := range commitments {
+			if err := rln.InsertMember(idComm); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	for i := 0; i < 5; i++ {
+		s.NoError(rln.EnableIndex())
+		_, _ = rln.IndexOf(commitments[0])
+	}
+	s.NoError(<-done)
+
+	// The insertions done while the index was rebuilt are not lost
+	for i, idComm := range commitments {
+		index, ok := rln.IndexOf(idComm)
+		s.True(ok)
+		s.Equal(MembershipIndex(i), index)
+	}
+}
@@ -0,0 +1,226 @@
+package rln
+
+import (
+	"fmt"
+)
+
+// IntegrityOptions configures CheckIntegrity
+type IntegrityOptions struct {
+	// Repair rewrites all the leaves of the tree when inconsistencies are found,
+	// so zerokit recalculates every node
+	Repair bool
+	// DecodeMetadata, if set, is used to check that the stored metadata exists and can be decoded
+	DecodeMetadata func(metadata []byte) error
+}
+
+// InconsistentNode is a node of the tree whose stored value does not match the
+// value derived from the leaves. Level 0 contains the leaves
+type InconsistentNode struct {
+	Level    int
+	Index    uint64
+	Stored   MerkleNode
+	Expected MerkleNode
+}
+
+// IntegrityReport contains the result of CheckIntegrity
+type IntegrityReport struct {
+	// Leaves is the number of leaves that were checked
+	Leaves MembershipIndex
+	// StoredRoot is the root returned by GetMerkleRoot before any repair
+	StoredRoot MerkleNode
+	// ComputedRoot is the root derived from the leaves
+	ComputedRoot MerkleNode
+	// InconsistentNodes contains the nodes found in the Merkle proofs that do not match the leaves
+	InconsistentNodes []InconsistentNode
+	// MetadataError is the error obtained reading or decoding the metadata
+	MetadataError error
+	// Repaired indicates that the tree was rebuilt from its leaves
+	Repaired bool
+}
+
+// Consistent indicates whether no problems were found in the tree
+func (r IntegrityReport) Consistent() bool {
+	return r.StoredRoot == r.ComputedRoot && len(r.InconsistentNodes) == 0 && r.MetadataError == nil
+}
+
+// CheckIntegrity derives the root from all the stored leaves and compares it to the stored root,
+// and checks the nodes of the Merkle proof of every leaf. Since it reads every leaf and proof it
+// is meant to be used offline, i.e. after a crash. The returned error indicates that the check
+// could not be done, while the problems found in the tree are described in the report
+func (r *RLN) CheckIntegrity(opts IntegrityOptions) (IntegrityReport, error) {
+	return r.checkIntegrity(r, opts)
+}
+
+// storedTree is the view of the stored tree checked by CheckIntegrity. zerokit keeps the nodes
+// consistent with the leaves through its API, so tests replace it to simulate a corrupted tree
+type storedTree interface {
+	LeavesSet() uint
+	GetMerkleRoot() (MerkleNode, error)
+	GetLeaves(start MembershipIndex, end MembershipIndex) ([]IDCommitment, error)
+	GetMerkleProof(index MembershipIndex) (MerkleProof, error)
+}
+
+func (r *RLN) checkIntegrity(tree storedTree, opts IntegrityOptions) (IntegrityReport, error) {
+	var report IntegrityReport
+	var err error
+
+	report.Leaves = MembershipIndex(tree.LeavesSet())
+
+	report.StoredRoot, err = tree.GetMerkleRoot()
+	if err != nil {
+		return IntegrityReport{}, err
+	}
+
+	leaves, err := tree.GetLeaves(0, report.Leaves)
+	if err != nil {
+		return IntegrityReport{}, err
+	}
+
+	levels, err := computeTreeLevels(leaves, r.depth)
+	if err != nil {
+		return IntegrityReport{}, err
+	}
+	report.ComputedRoot = levels.node(int(r.depth), 0)
+
+	for i := range leaves {
+		proof, err := tree.GetMerkleProof(MembershipIndex(i))
+		if err != nil {
+			return IntegrityReport{}, fmt.Errorf("could not obtain the merkle proof of leaf %d: %w", i, err)
+		}
+		report.InconsistentNodes = appendInconsistentNodes(report.InconsistentNodes, levels, uint64(i), proof)
+	}
+
+	report.MetadataError = r.checkMetadata(opts.DecodeMetadata)
+
+	if opts.Repair && (report.StoredRoot != report.ComputedRoot || len(report.InconsistentNodes) != 0) {
+		if err := r.repairTree(leaves, report.ComputedRoot); err != nil {
+			return report, fmt.Errorf("could not repair the tree: %w", err)
+		}
+		report.Repaired = true
+	}
+
+	return report, nil
+}
+
+func (r *RLN) checkMetadata(decode func([]byte) error) error {
+	if decode == nil {
+		return nil
+	}
+
+	// zerokit also fails when no metadata has been stored
+	metadata, err := r.GetMetadata()
+	if err != nil {
+		return fmt.Errorf("could not read metadata: %w", err)
+	}
+
+	if err := decode(metadata); err != nil {
+		return fmt.Errorf("could not decode metadata: %w", err)
+	}
+
+	return nil
+}
+
+// repairTree sets all the leaves again, which makes zerokit recalculate and store every node in
+// their paths. Unlike InitTreeWithMembers, it keeps the tree in its current location
+func (r *RLN) repairTree(leaves []IDCommitment, expectedRoot MerkleNode) error {
+	if len(leaves) != 0 {
+		if err := r.setLeavesFrom(0, leaves); err != nil {
+			return err
+		}
+	}
+
+	if r.treePath != "" {
+		if err := r.Flush(); err != nil {
+			return err
+		}
+	}
+
+	root, err := r.GetMerkleRoot()
+	if err != nil {
+		return err
+	}
+
+	if root != expectedRoot {
+		return fmt.Errorf("the root after rebuilding the tree is %x, expected %x", root, expectedRoot)
+	}
+
+	return nil
+}
+
+// treeLevels contains the nodes of a tree with a prefix of non empty leaves.
+// nodes[level] only contains the nodes whose subtree has at least one of those leaves,
+// the rest are the root of an empty subtree, stored in zeros[level]
+type treeLevels struct {
+	nodes [][]MerkleNode
+	zeros []MerkleNode
+}
+
+func (t treeLevels) node(level int, index uint64) MerkleNode {
+	if index < uint64(len(t.nodes[level])) {
+		return t.nodes[level][index]
+	}
+	return t.zeros[level]
+}
+
+// computeTreeLevels calculates all the nodes of a tree of the specified depth whose first leaves are `leaves`
+func computeTreeLevels(leaves []IDCommitment, depth TreeDepth) (treeLevels, error) {
+	result := treeLevels{
+		nodes: make([][]MerkleNode, depth+1),
+		zeros: make([]MerkleNode, depth+1),
+	}
+
+	for level := 1; level <= int(depth); level++ {
+		var err error
+		result.zeros[level], err = poseidonHash(result.zeros[level-1], result.zeros[level-1])
+		if err != nil {
+			return treeLevels{}, err
+		}
+	}
+
+	result.nodes[0] = make([]MerkleNode, len(leaves))
+	for i, leaf := range leaves {
+		result.nodes[0][i] = MerkleNode(leaf)
+	}
+
+	for level := 1; level <= int(depth); level++ {
+		children := result.nodes[level-1]
+		nodes := make([]MerkleNode, (len(children)+1)/2)
+		for i := range nodes {
+			var err error
+			nodes[i], err = poseidonHash(result.node(level-1, uint64(2*i)), result.node(level-1, uint64(2*i+1)))
+			if err != nil {
+				return treeLevels{}, err
+			}
+		}
+		result.nodes[level] = nodes
+	}
+
+	return result, nil
+}
+
+// appendInconsistentNodes compares the path elements of the proof of the leaf at `index` with
+// the expected nodes. Nodes shared with the proofs of previous leaves are reported once
+func appendInconsistentNodes(result []InconsistentNode, levels treeLevels, index uint64, proof MerkleProof) []InconsistentNode {
+	for level, stored := range proof.PathElements {
+		if level >= len(levels.nodes)-1 {
+			break
+		}
+
+		siblingIndex := (index >> level) ^ 1
+		// The sibling was already checked in the proof of the previous leaf of the subtree
+		if index&((1<<level)-1) != 0 {
+			continue
+		}
+
+		expected := levels.node(level, siblingIndex)
+		if stored != expected {
+			result = append(result, InconsistentNode{
+				Level:    level,
+				Index:    siblingIndex,
+				Stored:   stored,
+				Expected: expected,
+			})
+		}
+	}
+	return result
+}
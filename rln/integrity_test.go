@@ -0,0 +1,172 @@
+package rln
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func (s *RLNSuite) TestCheckIntegrity() {
+	rln, err := NewWithConfig(DefaultTreeDepth, &TreeConfig{
+		CacheCapacity: 15000,
+		Mode:          HighThroughput,
+		FlushInterval: DefaultTreeConfig().FlushInterval,
+		Path:          s.T().TempDir(),
+	})
+	s.NoError(err)
+
+	// Empty tree
+	report, err := rln.CheckIntegrity(IntegrityOptions{})
+	s.NoError(err)
+	s.True(report.Consistent())
+	s.Equal(MembershipIndex(0), report.Leaves)
+
+	for i := 0; i < 7; i++ {
+		err = rln.InsertMember(IDCommitment{byte(i + 1)})
+		s.NoError(err)
+	}
+	err = rln.DeleteMember(2)
+	s.NoError(err)
+
+	report, err = rln.CheckIntegrity(IntegrityOptions{})
+	s.NoError(err)
+	s.True(report.Consistent())
+	s.Equal(MembershipIndex(7), report.Leaves)
+	s.Empty(report.InconsistentNodes)
+
+	root, err := rln.GetMerkleRoot()
+	s.NoError(err)
+	s.Equal(root, report.StoredRoot)
+	s.Equal(root, report.ComputedRoot)
+
+	// Metadata
+	report, err = rln.CheckIntegrity(IntegrityOptions{DecodeMetadata: func([]byte) error { return nil }})
+	s.NoError(err)
+	s.Error(report.MetadataError)
+	s.False(report.Consistent())
+
+	err = rln.SetMetadata([]byte{1, 2, 3})
+	s.NoError(err)
+
+	report, err = rln.CheckIntegrity(IntegrityOptions{DecodeMetadata: func(b []byte) error {
+		if len(b) != 3 {
+			return errors.New("wrong length")
+		}
+		return nil
+	}})
+	s.NoError(err)
+	s.NoError(report.MetadataError)
+
+	// Rebuilding a consistent tree keeps the same root
+	err = rln.repairTree([]IDCommitment{{1}, {2}, {}, {4}, {5}, {6}, {7}}, root)
+	s.NoError(err)
+
+	report, err = rln.CheckIntegrity(IntegrityOptions{Repair: true})
+	s.NoError(err)
+	s.True(report.Consistent())
+	s.False(report.Repaired)
+}
+
+// corruptedTree simulates a tree whose stored node at `level` and `index`, and therefore its
+// root, do not match the leaves
+type corruptedTree struct {
+	*RLN
+	level int
+	index uint64
+}
+
+func (t corruptedTree) GetMerkleRoot() (MerkleNode, error) {
+	root, err := t.RLN.GetMerkleRoot()
+	root[0] ^= 0xff
+	return root, err
+}
+
+func (t corruptedTree) GetMerkleProof(index MembershipIndex) (MerkleProof, error) {
+	proof, err := t.RLN.GetMerkleProof(index)
+	if err == nil && (uint64(index)>>t.level)^1 == t.index {
+		proof.PathElements[t.level][0] ^= 0xff
+	}
+	return proof, err
+}
+
+func (s *RLNSuite) TestRepairCorruptedTree() {
+	rln, err := NewWithConfig(DefaultTreeDepth, &TreeConfig{
+		CacheCapacity: 15000,
+		Mode:          HighThroughput,
+		FlushInterval: DefaultTreeConfig().FlushInterval,
+		Path:          s.T().TempDir(),
+	})
+	s.NoError(err)
+
+	for i := 0; i < 7; i++ {
+		err = rln.InsertMember(IDCommitment{byte(i + 1)})
+		s.NoError(err)
+	}
+
+	root, err := rln.GetMerkleRoot()
+	s.NoError(err)
+
+	// The node at level 1 covering leaves 0 and 1 is the sibling in the proofs of leaves 2 and 3
+	tree := corruptedTree{RLN: rln, level: 1, index: 0}
+
+	report, err := rln.checkIntegrity(tree, IntegrityOptions{})
+	s.NoError(err)
+	s.False(report.Consistent())
+	s.False(report.Repaired)
+	s.Equal(root, report.ComputedRoot)
+	s.NotEqual(root, report.StoredRoot)
+	s.Len(report.InconsistentNodes, 1)
+	s.Equal(1, report.InconsistentNodes[0].Level)
+	s.Equal(uint64(0), report.InconsistentNodes[0].Index)
+
+	metrics := &recordingMetrics{}
+	rln.SetMetrics(metrics)
+
+	report, err = rln.checkIntegrity(tree, IntegrityOptions{Repair: true})
+	s.NoError(err)
+	s.False(report.Consistent())
+	s.True(report.Repaired)
+
+	// The leaves were written again
+	s.Contains(metrics.operations, observedOperation{op: OpSetLeaves})
+	s.Contains(metrics.operations, observedOperation{op: OpFlush})
+
+	report, err = rln.CheckIntegrity(IntegrityOptions{})
+	s.NoError(err)
+	s.True(report.Consistent())
+	s.Equal(root, report.StoredRoot)
+}
+
+func TestAppendInconsistentNodes(t *testing.T) {
+	leaves := []IDCommitment{{1}, {2}, {3}}
+	levels, err := computeTreeLevels(leaves, 3)
+	require.NoError(t, err)
+
+	proof := func(index uint64) MerkleProof {
+		var result MerkleProof
+		for level := 0; level < 3; level++ {
+			result.PathElements = append(result.PathElements, levels.node(level, (index>>level)^1))
+			result.PathIndexes = append(result.PathIndexes, uint8((index>>level)&1))
+		}
+		return result
+	}
+
+	for i := range leaves {
+		p := proof(uint64(i))
+		computedRoot, err := p.ComputeRoot(leaves[i])
+		require.NoError(t, err)
+		require.Equal(t, levels.node(3, 0), computedRoot)
+		require.Empty(t, appendInconsistentNodes(nil, levels, uint64(i), p))
+	}
+
+	// The sibling of leaf 2 at level 1 is the node 0
+	p := proof(2)
+	p.PathElements[1] = MerkleNode{0xff}
+	require.Equal(t, []InconsistentNode{{Level: 1, Index: 0, Stored: MerkleNode{0xff}, Expected: levels.node(1, 0)}}, appendInconsistentNodes(nil, levels, 2, p))
+
+	// Leaf 1 shares the sibling at level 1 with leaf 0, so it is not reported twice
+	p = proof(1)
+	p.PathElements[1] = MerkleNode{0xff}
+	require.Empty(t, appendInconsistentNodes(nil, levels, 1, p))
+}
@@ -0,0 +1,175 @@
+package rln
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// leafChange records the value a leaf had before being modified
+type leafChange struct {
+	index    MembershipIndex
+	previous IDCommitment
+}
+
+type journalEntry struct {
+	block   uint64
+	changes []leafChange
+}
+
+// journal keeps the changes applied to the tree on each block, so they can be undone
+type journal struct {
+	mu      sync.Mutex
+	entries []journalEntry
+	// prunedBlock is the oldest block the tree can be rolled back to
+	prunedBlock uint64
+}
+
+// ErrJournalDisabled is returned when using the journal without calling EnableJournal first
+var ErrJournalDisabled = errors.New("journal is not enabled")
+
+// EnableJournal starts recording the changes done with AtomicOperationAtBlock so they can be
+// reverted with RollbackTo. Changes done with other methods are not recorded. The journal is kept
+// in memory and grows with every operation, so it should be pruned with PruneJournal once blocks
+// are final
+func (r *RLN) EnableJournal() {
+	if r.journal == nil {
+		r.journal = &journal{}
+	}
+}
+
+// AtomicOperationAtBlock behaves like AtomicOperation, and records in the journal the leaves
+// modified by the operation, tagged with the number of the block that produced the changes.
+// If the root history is enabled, the resulting root is tagged with the block too.
+// Blocks must be applied in increasing order
+func (r *RLN) AtomicOperationAtBlock(block uint64, index MembershipIndex, idCommsToInsert []IDCommitment, indicesToRemove []MembershipIndex) error {
+	if r.journal == nil {
+		return ErrJournalDisabled
+	}
+
+	r.journal.mu.Lock()
+	defer r.journal.mu.Unlock()
+
+	if n := len(r.journal.entries); n != 0 && r.journal.entries[n-1].block > block {
+		return fmt.Errorf("block %d is older than the last journaled block %d", block, r.journal.entries[n-1].block)
+	}
+
+	if err := r.loadTree(); err != nil {
+		return err
+	}
+
+	// The previous values must not change before the operation is applied
+	if err := r.lockWrites(); err != nil {
+		return err
+	}
+	defer r.writeMu.Unlock()
+
+	changes, err := r.currentLeaves(index, len(idCommsToInsert), indicesToRemove)
+	if err != nil {
+		return err
+	}
+
+	r.SetBlockNumber(block)
+	if err := r.atomicOperation(index, idCommsToInsert, indicesToRemove); err != nil {
+		return err
+	}
+
+	r.journal.entries = append(r.journal.entries, journalEntry{block: block, changes: changes})
+
+	return nil
+}
+
+// currentLeaves returns the values of the leaves an atomic operation is going to modify
+func (r *RLN) currentLeaves(index MembershipIndex, insertions int, indicesToRemove []MembershipIndex) ([]leafChange, error) {
+	touched := make([]MembershipIndex, 0, insertions+len(indicesToRemove))
+	for i := 0; i < insertions; i++ {
+		touched = append(touched, index+MembershipIndex(i))
+	}
+	touched = append(touched, indicesToRemove...)
+
+	seen := make(map[MembershipIndex]struct{}, len(touched))
+	changes := make([]leafChange, 0, len(touched))
+	for _, i := range touched {
+		if _, ok := seen[i]; ok {
+			continue
+		}
+		seen[i] = struct{}{}
+
+		previous, err := r.GetLeaf(i)
+		if err != nil {
+			return nil, fmt.Errorf("could not read leaf %d: %w", i, err)
+		}
+		changes = append(changes, leafChange{index: i, previous: previous})
+	}
+
+	return changes, nil
+}
+
+// RollbackTo undoes the changes recorded for blocks newer than `block`, restoring the leaves
+// and the root the tree had after processing `block`, and removes the roots of those blocks
+// from the root history. Notice that LeavesSet is not decreased, so new members are inserted
+// after the ones rolled back
+func (r *RLN) RollbackTo(block uint64) error {
+	if r.journal == nil {
+		return ErrJournalDisabled
+	}
+
+	r.journal.mu.Lock()
+	defer r.journal.mu.Unlock()
+
+	if block < r.journal.prunedBlock {
+		return fmt.Errorf("cannot rollback to block %d, the journal was pruned up to block %d", block, r.journal.prunedBlock)
+	}
+
+	// The intermediate roots are not valid, and would evict valid ones from the history
+	r.pauseRootHistory(true)
+	defer r.pauseRootHistory(false)
+
+	for len(r.journal.entries) != 0 {
+		last := len(r.journal.entries) - 1
+		entry := r.journal.entries[last]
+		if entry.block <= block {
+			break
+		}
+
+		for i := len(entry.changes) - 1; i >= 0; i-- {
+			if err := r.restoreLeaf(entry.changes[i]); err != nil {
+				return fmt.Errorf("could not rollback block %d: %w", entry.block, err)
+			}
+		}
+
+		r.journal.entries = r.journal.entries[:last]
+	}
+
+	// Roots of orphaned blocks are no longer valid
+	r.forgetRootsAfter(block)
+	return nil
+}
+
+func (r *RLN) restoreLeaf(change leafChange) error {
+	if change.previous == (IDCommitment{}) {
+		return r.DeleteMember(change.index)
+	}
+	return r.InsertMemberAt(change.index, change.previous)
+}
+
+// PruneJournal discards the changes recorded for blocks up to `block` (inclusive).
+// Afterwards, the tree can not be rolled back to a block older than `block`
+func (r *RLN) PruneJournal(block uint64) {
+	if r.journal == nil {
+		return
+	}
+
+	r.journal.mu.Lock()
+	defer r.journal.mu.Unlock()
+
+	i := 0
+	for i < len(r.journal.entries) && r.journal.entries[i].block <= block {
+		i++
+	}
+	r.journal.entries = append([]journalEntry(nil), r.journal.entries[i:]...)
+
+	if block > r.journal.prunedBlock {
+		r.journal.prunedBlock = block
+	}
+}
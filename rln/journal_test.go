@@ -0,0 +1,139 @@
+package rln
+
+func (s *RLNSuite) TestJournalRollback() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	err = rln.AtomicOperationAtBlock(1, 0, []IDCommitment{{1}}, nil)
+	s.ErrorIs(err, ErrJournalDisabled)
+
+	rln.EnableJournal()
+
+	var commitments []IDCommitment
+	for i := 0; i < 6; i++ {
+		keypair, err := rln.MembershipKeyGen()
+		s.NoError(err)
+		commitments = append(commitments, keypair.IDCommitment)
+	}
+
+	roots := make(map[uint64]MerkleNode)
+
+	err = rln.AtomicOperationAtBlock(10, 0, commitments[:3], nil)
+	s.NoError(err)
+	roots[10], err = rln.GetMerkleRoot()
+	s.NoError(err)
+
+	err = rln.AtomicOperationAtBlock(11, 3, commitments[3:5], []MembershipIndex{1})
+	s.NoError(err)
+	roots[11], err = rln.GetMerkleRoot()
+	s.NoError(err)
+
+	// Overwrites a member and removes another one in the same block
+	err = rln.AtomicOperationAtBlock(12, 2, commitments[5:], nil)
+	s.NoError(err)
+	err = rln.AtomicOperationAtBlock(12, 0, nil, []MembershipIndex{0})
+	s.NoError(err)
+
+	// Blocks must be increasing
+	err = rln.AtomicOperationAtBlock(11, 0, nil, []MembershipIndex{3})
+	s.Error(err)
+
+	err = rln.RollbackTo(11)
+	s.NoError(err)
+	root, err := rln.GetMerkleRoot()
+	s.NoError(err)
+	s.Equal(roots[11], root)
+
+	leaf, err := rln.GetLeaf(0)
+	s.NoError(err)
+	s.Equal(commitments[0], leaf)
+
+	err = rln.RollbackTo(10)
+	s.NoError(err)
+	root, err = rln.GetMerkleRoot()
+	s.NoError(err)
+	s.Equal(roots[10], root)
+
+	leaves, err := rln.GetLeaves(0, 5)
+	s.NoError(err)
+	s.Equal([]IDCommitment{commitments[0], commitments[1], commitments[2], {}, {}}, leaves)
+
+	// The tree can be modified again after a rollback
+	err = rln.AtomicOperationAtBlock(11, 3, commitments[3:5], []MembershipIndex{1})
+	s.NoError(err)
+	root, err = rln.GetMerkleRoot()
+	s.NoError(err)
+	s.Equal(roots[11], root)
+
+	// Rolling back to a block before the first one empties the tree
+	err = rln.RollbackTo(0)
+	s.NoError(err)
+	root, err = rln.GetMerkleRoot()
+	s.NoError(err)
+	emptyRLN, err := NewRLN()
+	s.NoError(err)
+	emptyRoot, err := emptyRLN.GetMerkleRoot()
+	s.NoError(err)
+	s.Equal(emptyRoot, root)
+}
+
+func (s *RLNSuite) TestJournalPrune() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	rln.EnableJournal()
+
+	err = rln.AtomicOperationAtBlock(1, 0, []IDCommitment{{1}}, nil)
+	s.NoError(err)
+	err = rln.AtomicOperationAtBlock(2, 1, []IDCommitment{{2}}, nil)
+	s.NoError(err)
+	root, err := rln.GetMerkleRoot()
+	s.NoError(err)
+	err = rln.AtomicOperationAtBlock(3, 2, []IDCommitment{{3}}, nil)
+	s.NoError(err)
+
+	rln.PruneJournal(2)
+	s.Len(rln.journal.entries, 1)
+
+	err = rln.RollbackTo(1)
+	s.Error(err)
+
+	err = rln.RollbackTo(2)
+	s.NoError(err)
+	currentRoot, err := rln.GetMerkleRoot()
+	s.NoError(err)
+	s.Equal(root, currentRoot)
+}
+
+func (s *RLNSuite) TestJournalConcurrentWrites() {
+	rln, err := NewRLN()
+	s.NoError(err)
+	rln.EnableJournal()
+
+	journaled, concurrent := IDCommitment{1}, IDCommitment{2}
+	for i := 0; i < 20; i++ {
+		index := MembershipIndex(i)
+		block := uint64(i + 1)
+
+		done := make(chan error)
+		go func() {
+			done <- rln.InsertMemberAt(index, concurrent)
+		}()
+		s.NoError(rln.AtomicOperationAtBlock(block, index, []IDCommitment{journaled}, nil))
+		s.NoError(<-done)
+
+		// The journal must record the value the leaf had right before the operation,
+		// whichever of both writes was applied first
+		leaf, err := rln.GetLeaf(index)
+		s.NoError(err)
+		expected := IDCommitment{}
+		if leaf == journaled {
+			expected = concurrent
+		}
+
+		s.NoError(rln.RollbackTo(block - 1))
+		leaf, err = rln.GetLeaf(index)
+		s.NoError(err)
+		s.Equal(expected, leaf, "leaf %d", index)
+	}
+}
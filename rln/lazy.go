@@ -0,0 +1,207 @@
+package rln
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/waku-org/go-zerokit-rln/rln/link"
+)
+
+// Option configures an instance created with NewWithConfig
+type Option func(*options)
+
+type options struct {
+	lazy bool
+}
+
+// WithLazyLoading defers the creation of the zerokit instance, which loads the proving key and
+// the witness calculator and opens the tree, until it is first needed or Warmup is called.
+// zerokit can not open a tree without loading the proving key, so the first tree operation
+// pays for the load too. Verify, ExtractMetadata and RecoverIDSecret are done on the Go side
+// until then
+func WithLazyLoading() Option {
+	return func(o *options) {
+		o.lazy = true
+	}
+}
+
+// proverTreeHeight is the height of the tree of the zerokit instance created by NewLazyProver.
+// zerokit always creates a tree, which is not used when proving with a witness, so the smallest
+// one is used
+const proverTreeHeight = 1
+
+// lazyLoader creates the zerokit instance of an instance created with NewLazyProver or with the
+// WithLazyLoading option the first time it is needed
+type lazyLoader struct {
+	// hasTree indicates whether the zerokit instance holds the tree of the RLN instance,
+	// or is only used to generate proofs
+	hasTree    bool
+	treeHeight int
+	config     []byte
+
+	loaded atomic.Bool
+
+	mu           sync.Mutex
+	w            *link.RLNWrapper
+	loadDuration time.Duration
+	loadFailures int
+}
+
+func newLazyLoader(depth TreeDepth, treeConfig *TreeConfig, hasTree bool) (*lazyLoader, error) {
+	treeHeight := proverTreeHeight
+	if hasTree {
+		treeHeight = int(depth)
+	}
+
+	configBytes, err := json.Marshal(config{
+		ResourcesFolder: getResourcesFolder(depth),
+		TreeConfig:      treeConfig,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &lazyLoader{
+		hasTree:    hasTree,
+		treeHeight: treeHeight,
+		config:     configBytes,
+	}, nil
+}
+
+func (l *lazyLoader) load(r *RLN) (*link.RLNWrapper, error) {
+	if l.loaded.Load() {
+		return l.w, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.w != nil {
+		return l.w, nil
+	}
+
+	start := time.Now()
+	w, err := link.New(l.treeHeight, l.config)
+	r.getMetrics().ObserveOperation(OpLoadProvingKey, time.Since(start), err)
+	if err != nil {
+		// Not cached, so the load is attempted again on the next use
+		l.loadFailures++
+		return nil, err
+	}
+
+	if l.hasTree {
+		r.w = w
+		r.leafCount.known = w.LeavesSet() == 0
+	}
+	l.w = w
+	l.loadDuration = time.Since(start)
+	l.loaded.Store(true)
+	return w, nil
+}
+
+// NewLazyProver creates a prover-only RLN instance for the circuit of the specified depth.
+// The proving key and witness calculator are loaded on the first GenerateProof or
+// GenerateRLNProofWithWitness, or when calling Warmup. The Merkle paths used by GenerateProof
+// are obtained from `provider`, which can be nil if only GenerateRLNProofWithWitness is used.
+// Like in a verifier-only instance, the tree operations return ErrUnsupported, and Verify,
+// ExtractMetadata and RecoverIDSecret are done on the Go side
+func NewLazyProver(depth TreeDepth, provider MerkleProofProvider) (*RLN, error) {
+	r := &RLN{
+		depth:        depth,
+		merkleProofs: provider,
+	}
+
+	// Fails early if the circuit of this depth is not bundled
+	if _, err := r.verifyingKey(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	r.lazy, err = newLazyLoader(depth, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// hasTree indicates whether the instance supports the tree operations, even if its tree is not
+// loaded yet
+func (r *RLN) hasTree() bool {
+	if r.lazy != nil {
+		return r.lazy.hasTree
+	}
+	return r.w != nil
+}
+
+// treeLoaded indicates whether the tree of the instance can be used without loading it
+func (r *RLN) treeLoaded() bool {
+	if r.lazy != nil {
+		return r.lazy.hasTree && r.lazy.loaded.Load()
+	}
+	return r.w != nil
+}
+
+// loadTree makes r.w available, loading it in instances created with the WithLazyLoading
+// option. It returns ErrUnsupported in instances without a tree, and ErrClosed after Close
+func (r *RLN) loadTree() error {
+	if !r.hasTree() {
+		return ErrUnsupported
+	}
+	if r.closed.Load() {
+		return ErrClosed
+	}
+	if r.lazy == nil {
+		return nil
+	}
+	_, err := r.lazy.load(r)
+	return err
+}
+
+// provingWrapper returns the zerokit instance used to generate proofs, loading it if needed
+func (r *RLN) provingWrapper() (*link.RLNWrapper, error) {
+	if r.lazy != nil {
+		return r.lazy.load(r)
+	}
+	if err := r.loadTree(); err != nil {
+		return nil, err
+	}
+	return r.w, nil
+}
+
+// Warmup loads the proving key and the witness calculator of an instance created with
+// NewLazyProver or the WithLazyLoading option, so the first proof does not pay for it.
+// Other instances load them on creation, so it does nothing
+func (r *RLN) Warmup() error {
+	_, err := r.provingWrapper()
+	return err
+}
+
+// ProverStats describes the loading of the proving key
+type ProverStats struct {
+	// Loaded indicates whether the instance is able to generate proofs without loading
+	Loaded bool
+	// LoadDuration is the time it took to load the proving key and witness calculator
+	// of an instance created with NewLazyProver or the WithLazyLoading option. It is 0 in
+	// other instances
+	LoadDuration time.Duration
+	// LoadFailures is the number of failed attempts to load them
+	LoadFailures int
+}
+
+// ProverStats returns information about the loading of the proving key
+func (r *RLN) ProverStats() ProverStats {
+	if r.lazy == nil {
+		return ProverStats{Loaded: r.w != nil}
+	}
+
+	r.lazy.mu.Lock()
+	defer r.lazy.mu.Unlock()
+	return ProverStats{
+		Loaded:       r.lazy.w != nil,
+		LoadDuration: r.lazy.loadDuration,
+		LoadFailures: r.lazy.loadFailures,
+	}
+}
@@ -0,0 +1,144 @@
+package rln
+
+import (
+	"os"
+	"path/filepath"
+)
+
+func (s *RLNSuite) TestLazyProver() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	memKeys, err := rln.MembershipKeyGen()
+	s.NoError(err)
+
+	err = rln.InsertMember(memKeys.IDCommitment)
+	s.NoError(err)
+
+	root, err := rln.GetMerkleRoot()
+	s.NoError(err)
+
+	_, err = NewLazyProver(TreeDepth(3), rln)
+	s.Error(err)
+
+	prover, err := NewLazyProver(DefaultTreeDepth, rln)
+	s.NoError(err)
+	s.False(prover.ProverStats().Loaded)
+	// Only the smallest tree is created with the proving key
+	s.Equal(proverTreeHeight, prover.lazy.treeHeight)
+
+	// The tree is not available
+	_, err = prover.GetMerkleRoot()
+	s.ErrorIs(err, ErrUnsupported)
+
+	proof, err := prover.GenerateProof([]byte("Hello"), *memKeys, MembershipIndex(0), ToEpoch(1000))
+	s.NoError(err)
+	s.Equal(root, proof.MerkleRoot)
+
+	stats := prover.ProverStats()
+	s.True(stats.Loaded)
+	s.Greater(stats.LoadDuration.Nanoseconds(), int64(0))
+	s.Equal(0, stats.LoadFailures)
+
+	// Loading again does nothing
+	s.NoError(prover.Warmup())
+	s.Equal(stats, prover.ProverStats())
+
+	verified, err := rln.Verify([]byte("Hello"), *proof, root)
+	s.NoError(err)
+	s.True(verified)
+
+	verified, err = prover.Verify([]byte("Hello"), *proof, root)
+	s.NoError(err)
+	s.True(verified)
+
+	// Without provider only GenerateRLNProofWithWitness is available
+	witnessProver, err := NewLazyProver(DefaultTreeDepth, nil)
+	s.NoError(err)
+
+	_, err = witnessProver.GenerateProof([]byte("Hello"), *memKeys, MembershipIndex(0), ToEpoch(1000))
+	s.ErrorIs(err, ErrUnsupported)
+
+	s.NoError(witnessProver.Warmup())
+	s.True(witnessProver.ProverStats().Loaded)
+
+	// Full instances are loaded on creation and verifiers can not load them
+	s.NoError(rln.Warmup())
+	s.Equal(ProverStats{Loaded: true}, rln.ProverStats())
+
+	verifKey, err := builtinVerifyingKey(DefaultTreeDepth)
+	s.NoError(err)
+	verifier, err := NewVerifier(verifKey)
+	s.NoError(err)
+	s.ErrorIs(verifier.Warmup(), ErrUnsupported)
+	s.False(verifier.ProverStats().Loaded)
+}
+
+func (s *RLNSuite) TestLazyLoading() {
+	_, err := NewWithConfig(TreeDepth(3), nil, WithLazyLoading())
+	s.Error(err)
+
+	treePath := filepath.Join(s.T().TempDir(), "tree")
+	rln, err := NewWithConfig(DefaultTreeDepth, &TreeConfig{
+		CacheCapacity: 15000,
+		Mode:          HighThroughput,
+		FlushInterval: DefaultTreeConfig().FlushInterval,
+		Path:          treePath,
+	}, WithLazyLoading())
+	s.NoError(err)
+	s.False(rln.ProverStats().Loaded)
+
+	// The tree is not opened until it is needed
+	_, err = os.Stat(treePath)
+	s.ErrorIs(err, os.ErrNotExist)
+
+	full, err := NewRLN()
+	s.NoError(err)
+
+	memKeys, err := full.MembershipKeyGen()
+	s.NoError(err)
+
+	err = full.InsertMember(memKeys.IDCommitment)
+	s.NoError(err)
+
+	root, err := full.GetMerkleRoot()
+	s.NoError(err)
+
+	proof, err := full.GenerateProof([]byte("Hello"), *memKeys, MembershipIndex(0), ToEpoch(1000))
+	s.NoError(err)
+
+	// Proofs are verified on the Go side without loading
+	verified, err := rln.Verify([]byte("Hello"), *proof, root)
+	s.NoError(err)
+	s.True(verified)
+	s.False(rln.ProverStats().Loaded)
+
+	// The first tree operation loads the instance
+	err = rln.InsertMember(memKeys.IDCommitment)
+	s.NoError(err)
+
+	stats := rln.ProverStats()
+	s.True(stats.Loaded)
+	s.Greater(stats.LoadDuration.Nanoseconds(), int64(0))
+	s.Equal(0, stats.LoadFailures)
+
+	_, err = os.Stat(treePath)
+	s.NoError(err)
+
+	lazyRoot, err := rln.GetMerkleRoot()
+	s.NoError(err)
+	s.Equal(root, lazyRoot)
+
+	proof, err = rln.GenerateProof([]byte("Hello"), *memKeys, MembershipIndex(0), ToEpoch(1000))
+	s.NoError(err)
+	s.Equal(root, proof.MerkleRoot)
+
+	verified, err = full.Verify([]byte("Hello"), *proof, root)
+	s.NoError(err)
+	s.True(verified)
+
+	// Loading again does nothing
+	s.NoError(rln.Warmup())
+	s.Equal(stats, rln.ProverStats())
+	s.NoError(rln.Close())
+}
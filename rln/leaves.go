@@ -0,0 +1,171 @@
+package rln
+
+import (
+	"fmt"
+	"sync"
+)
+
+// capacity returns the number of leaves the tree can hold
+func (r *RLN) capacity() uint64 {
+	return uint64(1) << uint64(r.depth)
+}
+
+// Number of levels of empty subtrees precomputed, enough for any supported depth
+const zeroSubtreeLevels = 32
+
+var zeroSubtrees struct {
+	once  sync.Once
+	roots []MerkleNode
+	err   error
+}
+
+// zeroSubtreeRoots returns the roots of the empty subtrees of each height, the one of height 0
+// being a zero leaf
+func zeroSubtreeRoots() ([]MerkleNode, error) {
+	zeroSubtrees.once.Do(func() {
+		roots := make([]MerkleNode, zeroSubtreeLevels+1)
+		for i := 1; i < len(roots); i++ {
+			roots[i], zeroSubtrees.err = poseidonHash(roots[i-1], roots[i-1])
+			if zeroSubtrees.err != nil {
+				return
+			}
+		}
+		zeroSubtrees.roots = roots
+	})
+	return zeroSubtrees.roots, zeroSubtrees.err
+}
+
+// emptySubtreeEnd returns the index following the largest empty subtree containing the zero
+// leaf at `index`. The subtree is found comparing the path elements of the leaf with the roots
+// of the empty subtrees, which only requires one call to zerokit
+func (r *RLN) emptySubtreeEnd(index MembershipIndex) (MembershipIndex, error) {
+	proof, err := r.GetMerkleProof(index)
+	if err != nil {
+		return 0, fmt.Errorf("could not read the merkle proof of leaf %d: %w", index, err)
+	}
+
+	zeros, err := zeroSubtreeRoots()
+	if err != nil {
+		return 0, err
+	}
+
+	height := 0
+	for height < len(proof.PathElements) && height < zeroSubtreeLevels && proof.PathElements[height] == zeros[height] {
+		height++
+	}
+
+	return ((index >> height) + 1) << height, nil
+}
+
+// GetLeaves returns the leaves stored in the range [start, end) of the Merkle tree.
+// Unset and deleted leaves are returned as zero values. zerokit does not provide a way
+// to read several leaves at once, so every non-zero leaf requires a call to zerokit, and
+// the ranges of empty leaves are skipped using the Merkle proofs
+func (r *RLN) GetLeaves(start MembershipIndex, end MembershipIndex) ([]IDCommitment, error) {
+	if err := r.loadTree(); err != nil {
+		return nil, err
+	}
+
+	if start > end {
+		return nil, fmt.Errorf("invalid range: start %d is greater than end %d", start, end)
+	}
+
+	if uint64(end) > r.capacity() {
+		return nil, fmt.Errorf("invalid range: end %d exceeds the tree capacity %d", end, r.capacity())
+	}
+
+	result := make([]IDCommitment, end-start)
+	for i := start; i < end; {
+		leaf, err := r.GetLeaf(i)
+		if err != nil {
+			return nil, fmt.Errorf("could not read leaf %d: %w", i, err)
+		}
+
+		if leaf != (IDCommitment{}) {
+			result[i-start] = leaf
+			i++
+			continue
+		}
+
+		// The leaves of the empty subtree are already zero in the result
+		i, err = r.emptySubtreeEnd(i)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// LeafIterator walks the non-zero leaves of the tree in index order, skipping the empty
+// subtrees like GetLeaves. Leaves inserted after the iterator was created are not visited
+//
+//	it := r.Leaves(0)
+//	for it.Next() {
+//		fmt.Println(it.Index(), it.Leaf())
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type LeafIterator struct {
+	r     *RLN
+	next  MembershipIndex
+	end   MembershipIndex
+	index MembershipIndex
+	leaf  IDCommitment
+	err   error
+}
+
+// Leaves returns an iterator over the non-zero leaves of the tree, starting from index `start`
+func (r *RLN) Leaves(start MembershipIndex) *LeafIterator {
+	if err := r.loadTree(); err != nil {
+		return &LeafIterator{r: r, err: err}
+	}
+
+	return &LeafIterator{
+		r:    r,
+		next: start,
+		end:  MembershipIndex(r.LeavesSet()),
+	}
+}
+
+// Next advances the iterator to the next non-zero leaf. It returns false when
+// there are no more leaves or an error occurred, which can be checked with Err
+func (it *LeafIterator) Next() bool {
+	for it.err == nil && it.next < it.end {
+		index := it.next
+
+		leaf, err := it.r.GetLeaf(index)
+		if err != nil {
+			it.err = fmt.Errorf("could not read leaf %d: %w", index, err)
+			return false
+		}
+
+		if leaf == (IDCommitment{}) {
+			it.next, it.err = it.r.emptySubtreeEnd(index)
+			continue
+		}
+
+		it.next++
+		it.index = index
+		it.leaf = leaf
+		return true
+	}
+
+	return false
+}
+
+// Index returns the index of the current leaf
+func (it *LeafIterator) Index() MembershipIndex {
+	return it.index
+}
+
+// Leaf returns the value of the current leaf
+func (it *LeafIterator) Leaf() IDCommitment {
+	return it.leaf
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *LeafIterator) Err() error {
+	return it.err
+}
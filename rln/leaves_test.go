@@ -0,0 +1,129 @@
+package rln
+
+func (s *RLNSuite) TestGetLeaves() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	var commitments []IDCommitment
+	for i := 0; i < 5; i++ {
+		keypair, err := rln.MembershipKeyGen()
+		s.NoError(err)
+		commitments = append(commitments, keypair.IDCommitment)
+	}
+
+	err = rln.InsertMembers(0, commitments)
+	s.NoError(err)
+
+	err = rln.DeleteMember(2)
+	s.NoError(err)
+
+	leaves, err := rln.GetLeaves(1, 4)
+	s.NoError(err)
+	s.Equal([]IDCommitment{commitments[1], {}, commitments[3]}, leaves)
+
+	// Leaves beyond the next index are zero
+	leaves, err = rln.GetLeaves(5, 7)
+	s.NoError(err)
+	s.Equal([]IDCommitment{{}, {}}, leaves)
+
+	leaves, err = rln.GetLeaves(3, 3)
+	s.NoError(err)
+	s.Empty(leaves)
+
+	_, err = rln.GetLeaves(4, 3)
+	s.Error(err)
+
+	_, err = rln.GetLeaves(0, 1<<20+1)
+	s.Error(err)
+}
+
+func (s *RLNSuite) TestLeafIterator() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	var commitments []IDCommitment
+	for i := 0; i < 5; i++ {
+		keypair, err := rln.MembershipKeyGen()
+		s.NoError(err)
+		commitments = append(commitments, keypair.IDCommitment)
+	}
+
+	err = rln.InsertMembers(0, commitments)
+	s.NoError(err)
+
+	err = rln.DeleteMember(0)
+	s.NoError(err)
+	err = rln.DeleteMember(3)
+	s.NoError(err)
+
+	var indexes []MembershipIndex
+	var leaves []IDCommitment
+	it := rln.Leaves(0)
+	for it.Next() {
+		indexes = append(indexes, it.Index())
+		leaves = append(leaves, it.Leaf())
+	}
+	s.NoError(it.Err())
+	s.Equal([]MembershipIndex{1, 2, 4}, indexes)
+	s.Equal([]IDCommitment{commitments[1], commitments[2], commitments[4]}, leaves)
+
+	// Early stop and custom start
+	it = rln.Leaves(2)
+	s.True(it.Next())
+	s.Equal(MembershipIndex(2), it.Index())
+	s.Equal(commitments[2], it.Leaf())
+	s.NoError(it.Err())
+
+	// Empty tree
+	rln2, err := NewRLN()
+	s.NoError(err)
+	s.False(rln2.Leaves(0).Next())
+}
+
+func (s *RLNSuite) TestLeavesSkipEmptySubtrees() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	// The root of an empty tree is the root of the empty subtree of the tree height
+	zeros, err := zeroSubtreeRoots()
+	s.NoError(err)
+	root, err := rln.GetMerkleRoot()
+	s.NoError(err)
+	s.Equal(zeros[DefaultTreeDepth], root)
+
+	err = rln.InsertMembers(0, []IDCommitment{{1}, {2}})
+	s.NoError(err)
+	err = rln.InsertMembers(5000, []IDCommitment{{3}})
+	s.NoError(err)
+	err = rln.DeleteMember(1)
+	s.NoError(err)
+
+	metrics := &recordingMetrics{}
+	rln.SetMetrics(metrics)
+
+	var indexes []MembershipIndex
+	it := rln.Leaves(0)
+	for it.Next() {
+		indexes = append(indexes, it.Index())
+	}
+	s.NoError(it.Err())
+	s.Equal([]MembershipIndex{0, 5000}, indexes)
+
+	// Only a few leaves of the gap are read
+	s.Less(len(metrics.operations), 100)
+
+	metrics.operations = nil
+	leaves, err := rln.GetLeaves(0, 5010)
+	s.NoError(err)
+	s.Len(leaves, 5010)
+	s.Less(len(metrics.operations), 100)
+
+	expected := make([]IDCommitment, 5010)
+	expected[0] = IDCommitment{1}
+	expected[5000] = IDCommitment{3}
+	s.Equal(expected, leaves)
+
+	leaves, err = rln.GetLeaves(4999, 5001)
+	s.NoError(err)
+	s.Equal([]IDCommitment{{}, {3}}, leaves)
+}
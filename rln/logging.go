@@ -0,0 +1,72 @@
+package rln
+
+import (
+	"fmt"
+	"time"
+)
+
+// Logger receives the debug records of a RLN instance. It is the subset of *slog.Logger used
+// by this package, so a *slog.Logger can be used directly. args are alternating keys and values
+type Logger interface {
+	Debug(msg string, args ...any)
+}
+
+// redacted replaces the values that must never be logged
+const redacted = "[REDACTED]"
+
+// secretKeys are the keys whose values are always redacted
+var secretKeys = map[string]bool{
+	"id_secret_hash": true,
+	"id_trapdoor":    true,
+	"id_nullifier":   true,
+	"identity":       true,
+	"witness":        true,
+}
+
+// SetLogger configures the Logger that receives a debug record for every operation of this
+// instance, with its name, sizes, duration and outcome. Identity secrets are never included.
+// Using nil disables logging
+func (r *RLN) SetLogger(logger Logger) {
+	r.logger = logger
+}
+
+// logOperation records the outcome of an operation
+func (r *RLN) logOperation(op Operation, duration time.Duration, err error, attrs []any) {
+	if r.logger == nil {
+		return
+	}
+
+	args := make([]any, 0, len(attrs)+8)
+	args = append(args, "op", string(op), "depth", int(r.depth), "duration", duration)
+	args = append(args, attrs...)
+	if err != nil {
+		args = append(args, "error", err.Error())
+	}
+
+	r.logger.Debug("rln operation", redactArgs(args)...)
+}
+
+// redactArgs removes identity secrets from the arguments of a record. The values of secretKeys,
+// and any value containing an identity secret, are replaced, whatever the key they are logged with.
+// Secrets are [32]byte values like the public ones, so they can not be recognized by their type
+// and must never be passed as a value on their own
+func redactArgs(args []any) []any {
+	for i := 0; i+1 < len(args); i += 2 {
+		if key, ok := args[i].(string); ok && secretKeys[key] {
+			args[i+1] = redacted
+			continue
+		}
+
+		switch v := args[i+1].(type) {
+		case IdentityCredential:
+			args[i+1] = fmt.Sprintf("{id_commitment:%x}", v.IDCommitment)
+		case *IdentityCredential:
+			if v != nil {
+				args[i+1] = fmt.Sprintf("{id_commitment:%x}", v.IDCommitment)
+			}
+		case RLNWitnessInput, *RLNWitnessInput:
+			args[i+1] = redacted
+		}
+	}
+	return args
+}
@@ -0,0 +1,91 @@
+package rln
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLogger struct {
+	mu      sync.Mutex
+	records []string
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, fmt.Sprintln(append([]any{msg}, args...)...))
+}
+
+func (s *RLNSuite) TestLogger() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	memKeys, err := rln.MembershipKeyGen()
+	s.NoError(err)
+
+	logger := &recordingLogger{}
+	rln.SetLogger(logger)
+
+	err = rln.InsertMembers(0, []IDCommitment{memKeys.IDCommitment, {2}})
+	s.NoError(err)
+
+	_, err = rln.GenerateProof([]byte("Hello"), *memKeys, MembershipIndex(0), ToEpoch(1000))
+	s.NoError(err)
+
+	err = rln.InsertMembers(1<<20, []IDCommitment{{3}})
+	s.Error(err)
+
+	s.Len(logger.records, 3)
+	s.Contains(logger.records[0], "op insert_members")
+	s.Contains(logger.records[0], "count 2")
+	s.Contains(logger.records[1], "op generate_proof")
+	s.Contains(logger.records[1], "epoch 1000")
+	s.Contains(logger.records[2], "error "+ErrTreeFull.Error())
+
+	for _, record := range logger.records {
+		for _, secret := range [][32]byte{memKeys.IDSecretHash, memKeys.IDTrapdoor, memKeys.IDNullifier} {
+			s.NotContains(record, fmt.Sprintf("%x", secret))
+			s.NotContains(record, fmt.Sprint(secret))
+		}
+	}
+
+	rln.SetLogger(nil)
+	_, err = rln.GetMerkleRoot()
+	s.NoError(err)
+	s.Len(logger.records, 3)
+}
+
+func TestRedactArgs(t *testing.T) {
+	key := IdentityCredential{
+		IDTrapdoor:   [32]byte{1},
+		IDNullifier:  [32]byte{2},
+		IDSecretHash: [32]byte{3},
+		IDCommitment: [32]byte{4},
+	}
+
+	args := redactArgs([]any{
+		"id_secret_hash", key.IDSecretHash,
+		"credential", key,
+		"credential_ptr", &key,
+		"witness_input", RLNWitnessInput{IDSecretHash: key.IDSecretHash},
+		"index", 5,
+		"error", errors.New("failed").Error(),
+	})
+
+	require.Equal(t, []any{
+		"id_secret_hash", redacted,
+		"credential", fmt.Sprintf("{id_commitment:%x}", key.IDCommitment),
+		"credential_ptr", fmt.Sprintf("{id_commitment:%x}", key.IDCommitment),
+		"witness_input", redacted,
+		"index", 5,
+		"error", "failed",
+	}, args)
+
+	formatted := fmt.Sprint(args...)
+	require.False(t, strings.Contains(formatted, fmt.Sprintf("%x", key.IDSecretHash)))
+}
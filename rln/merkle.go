@@ -0,0 +1,60 @@
+package rln
+
+import (
+	"fmt"
+)
+
+// ValidateDepth checks that the proof is well formed for a tree of the specified depth:
+// it must contain one path element and one path index per level, and every index must be 0 or 1
+func (r MerkleProof) ValidateDepth(depth TreeDepth) error {
+	if len(r.PathElements) != int(depth) {
+		return fmt.Errorf("invalid merkle proof: expected %d path elements, got %d", depth, len(r.PathElements))
+	}
+
+	if len(r.PathIndexes) != int(depth) {
+		return fmt.Errorf("invalid merkle proof: expected %d path indexes, got %d", depth, len(r.PathIndexes))
+	}
+
+	for i, pathIndex := range r.PathIndexes {
+		if pathIndex > 1 {
+			return fmt.Errorf("invalid merkle proof: path index %d has value %d", i, pathIndex)
+		}
+	}
+
+	return nil
+}
+
+// ComputeRoot returns the root of the tree obtained by hashing the leaf with the
+// path elements of the proof. A path index of 0 indicates that the node is the
+// left child, and 1 that it is the right child
+// Equivalent to: https://github.com/vacp2p/zerokit/blob/v0.3.5/utils/src/merkle_tree/merkle_tree.rs (compute_root_from)
+func (r MerkleProof) ComputeRoot(leaf IDCommitment) (MerkleNode, error) {
+	if err := r.ValidateDepth(TreeDepth(len(r.PathElements))); err != nil {
+		return MerkleNode{}, err
+	}
+
+	node := MerkleNode(leaf)
+	for i, sibling := range r.PathElements {
+		var err error
+		if r.PathIndexes[i] == 0 {
+			node, err = poseidonHash(node, sibling)
+		} else {
+			node, err = poseidonHash(sibling, node)
+		}
+		if err != nil {
+			return MerkleNode{}, err
+		}
+	}
+
+	return node, nil
+}
+
+// Verify indicates whether the proof shows that the leaf is part of the tree with the specified root
+func (r MerkleProof) Verify(leaf IDCommitment, root MerkleNode) (bool, error) {
+	computedRoot, err := r.ComputeRoot(leaf)
+	if err != nil {
+		return false, err
+	}
+
+	return computedRoot == root, nil
+}
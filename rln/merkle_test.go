@@ -0,0 +1,76 @@
+package rln
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerkleProofValidateDepth(t *testing.T) {
+	proof := MerkleProof{
+		PathElements: make([]MerkleNode, 20),
+		PathIndexes:  make([]uint8, 20),
+	}
+	require.NoError(t, proof.ValidateDepth(TreeDepth20))
+	require.Error(t, proof.ValidateDepth(TreeDepth19))
+
+	proof.PathIndexes = proof.PathIndexes[:19]
+	require.Error(t, proof.ValidateDepth(TreeDepth20))
+
+	proof.PathIndexes = make([]uint8, 20)
+	proof.PathIndexes[3] = 2
+	require.Error(t, proof.ValidateDepth(TreeDepth20))
+
+	_, err := proof.ComputeRoot(IDCommitment{})
+	require.Error(t, err)
+}
+
+func (s *RLNSuite) TestMerkleProofComputeRoot() {
+	for _, treeDepth := range []TreeDepth{TreeDepth15, TreeDepth19, TreeDepth20} {
+		rln, err := NewWithConfig(treeDepth, nil)
+		s.NoError(err)
+
+		// Empty tree
+		root, err := rln.GetMerkleRoot()
+		s.NoError(err)
+
+		proof, err := rln.GetMerkleProof(0)
+		s.NoError(err)
+
+		computedRoot, err := proof.ComputeRoot(IDCommitment{})
+		s.NoError(err)
+		s.Equal(root, computedRoot)
+
+		var leaves []IDCommitment
+		for i := 0; i < 7; i++ {
+			keys, err := rln.MembershipKeyGen()
+			s.NoError(err)
+			leaves = append(leaves, keys.IDCommitment)
+		}
+
+		err = rln.InsertMembers(0, leaves)
+		s.NoError(err)
+
+		root, err = rln.GetMerkleRoot()
+		s.NoError(err)
+
+		for i, leaf := range leaves {
+			proof, err := rln.GetMerkleProof(MembershipIndex(i))
+			s.NoError(err)
+			s.NoError(proof.ValidateDepth(treeDepth))
+
+			computedRoot, err := proof.ComputeRoot(leaf)
+			s.NoError(err)
+			s.Equal(root, computedRoot)
+
+			valid, err := proof.Verify(leaf, root)
+			s.NoError(err)
+			s.True(valid)
+
+			// The leaf at another position is not accepted
+			valid, err = proof.Verify(leaves[(i+1)%len(leaves)], root)
+			s.NoError(err)
+			s.False(valid)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package rln
+
+import (
+	"time"
+)
+
+// Operation identifies an operation of a RLN instance in the Metrics
+type Operation string
+
+const (
+	OpSetTree                     Operation = "set_tree"
+	OpInitTreeWithMembers         Operation = "init_tree_with_members"
+	OpSetLeaves                   Operation = "set_leaves"
+	OpKeyGen                      Operation = "key_gen"
+	OpSha256                      Operation = "sha256"
+	OpPoseidon                    Operation = "poseidon"
+	OpExtractMetadata             Operation = "extract_metadata"
+	OpGenerateProof               Operation = "generate_proof"
+	OpGenerateRLNProofWithWitness Operation = "generate_rln_proof_with_witness"
+	OpVerify                      Operation = "verify"
+	OpRecoverIDSecret             Operation = "recover_id_secret"
+	OpInsertMember                Operation = "insert_member"
+	OpInsertMembers               Operation = "insert_members"
+	OpInsertMemberAt              Operation = "insert_member_at"
+	OpDeleteMember                Operation = "delete_member"
+	OpDeleteMembers               Operation = "delete_members"
+	OpGetMerkleRoot               Operation = "get_merkle_root"
+	OpGetLeaf                     Operation = "get_leaf"
+	OpGetMerkleProof              Operation = "get_merkle_proof"
+	OpSetMetadata                 Operation = "set_metadata"
+	OpGetMetadata                 Operation = "get_metadata"
+	OpAtomicOperation             Operation = "atomic_operation"
+	OpFlush                       Operation = "flush"
+	OpLoadProvingKey              Operation = "load_proving_key"
+	OpLoadRootHistory             Operation = "load_root_history"
+	OpSaveRootHistory             Operation = "save_root_history"
+	OpPrecompute                  Operation = "precompute"
+)
+
+// treeOperations are the operations that modify the tree. The tree size is reported after each one of them
+var treeOperations = map[Operation]bool{
+	OpSetTree:             true,
+	OpInitTreeWithMembers: true,
+	OpSetLeaves:           true,
+	OpInsertMember:        true,
+	OpInsertMembers:       true,
+	OpInsertMemberAt:      true,
+	OpDeleteMember:        true,
+	OpDeleteMembers:       true,
+	OpAtomicOperation:     true,
+}
+
+// Metrics receives measurements of the operations done by a RLN instance. Implementations
+// must be safe for concurrent use. See the prometheus subpackage for an implementation
+type Metrics interface {
+	// ObserveOperation is called after every operation with its duration.
+	// err is the error returned by the operation, or nil if it succeeded
+	ObserveOperation(op Operation, duration time.Duration, err error)
+	// SetTreeSize is called after every successful modification of the tree
+	// with the number of leaves set
+	SetTreeSize(leaves uint)
+}
+
+// VerifyCacheMetrics can be implemented by a Metrics to also receive the lookups in the
+// VerifyCache of the instance
+type VerifyCacheMetrics interface {
+	// ObserveVerifyCache is called on every lookup in the VerifyCache, indicating
+	// whether the outcome of the verification was found
+	ObserveVerifyCache(hit bool)
+}
+
+// NoopMetrics discards all the measurements. It is the Metrics used by default
+type NoopMetrics struct{}
+
+func (NoopMetrics) ObserveOperation(Operation, time.Duration, error) {}
+
+func (NoopMetrics) SetTreeSize(uint) {}
+
+// SetMetrics configures the Metrics that receives the measurements of this instance.
+// Using nil restores the NoopMetrics
+func (r *RLN) SetMetrics(metrics Metrics) {
+	r.metrics = metrics
+}
+
+func (r *RLN) getMetrics() Metrics {
+	if r.metrics == nil {
+		return NoopMetrics{}
+	}
+	return r.metrics
+}
@@ -0,0 +1,81 @@
+package rln
+
+import (
+	"sync"
+	"time"
+)
+
+type observedOperation struct {
+	op     Operation
+	failed bool
+}
+
+type recordingMetrics struct {
+	mu         sync.Mutex
+	operations []observedOperation
+	treeSize   uint
+	cacheHits  int
+	cacheMiss  int
+}
+
+func (m *recordingMetrics) ObserveOperation(op Operation, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.operations = append(m.operations, observedOperation{op: op, failed: err != nil})
+}
+
+func (m *recordingMetrics) SetTreeSize(leaves uint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.treeSize = leaves
+}
+
+func (m *recordingMetrics) ObserveVerifyCache(hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if hit {
+		m.cacheHits++
+	} else {
+		m.cacheMiss++
+	}
+}
+
+func (s *RLNSuite) TestMetrics() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	metrics := &recordingMetrics{}
+	rln.SetMetrics(metrics)
+
+	err = rln.InsertMembers(0, []IDCommitment{{1}, {2}, {3}})
+	s.NoError(err)
+	s.Equal(uint(3), metrics.treeSize)
+
+	err = rln.DeleteMember(1)
+	s.NoError(err)
+
+	_, err = rln.GetMerkleRoot()
+	s.NoError(err)
+
+	// The tree is full at index 2^20
+	err = rln.InsertMembers(1<<20, []IDCommitment{{4}})
+	s.ErrorIs(err, ErrTreeFull)
+
+	err = rln.Flush()
+	s.NoError(err)
+
+	s.Equal([]observedOperation{
+		{op: OpInsertMembers},
+		{op: OpDeleteMember},
+		{op: OpGetMerkleRoot},
+		{op: OpInsertMembers, failed: true},
+		{op: OpFlush},
+	}, metrics.operations)
+	s.Equal(uint(3), metrics.treeSize)
+
+	// Restoring the default stops the measurements
+	rln.SetMetrics(nil)
+	_, err = rln.GetMerkleRoot()
+	s.NoError(err)
+	s.Len(metrics.operations, 5)
+}
@@ -0,0 +1,85 @@
+package rln
+
+import (
+	"context"
+	"time"
+)
+
+// operation measures, logs and traces a call to a method of RLN
+type operation struct {
+	// ctx contains the span of the operation, so it is the parent of the nested operations
+	ctx   context.Context
+	r     *RLN
+	op    Operation
+	start time.Time
+	attrs []any
+	span  Span
+}
+
+// startOperation starts measuring an operation. attrs are alternating keys and values describing
+// it, added to the log record and the span. The span is a child of the one in ctx, if any
+func (r *RLN) startOperation(ctx context.Context, op Operation, attrs ...any) operation {
+	o := operation{
+		ctx:   ctx,
+		r:     r,
+		op:    op,
+		start: time.Now(),
+		attrs: attrs,
+	}
+	if r.tracer != nil {
+		o.ctx, o.span = r.tracer.Start(ctx, spanName(op))
+		o.span.SetAttribute(attributePrefix+"depth", int(r.depth))
+		setSpanAttributes(o.span, attrs)
+	}
+	return o
+}
+
+// recording indicates whether the attributes of the operation are logged or traced. Hot paths
+// use it to avoid building the attributes, which allocates
+func (o *operation) recording() bool {
+	return o.span != nil || o.r.logger != nil
+}
+
+// describe adds attributes to an operation after it started
+func (o *operation) describe(attrs ...any) {
+	o.attrs = append(o.attrs, attrs...)
+	if o.span != nil {
+		setSpanAttributes(o.span, attrs)
+	}
+}
+
+// end finishes an operation. err points to the error returned by the method, nil if it succeeded,
+// and results are alternating keys and values describing the result of the operation
+func (o operation) end(err *error, results ...any) {
+	duration := time.Since(o.start)
+
+	metrics := o.r.getMetrics()
+	metrics.ObserveOperation(o.op, duration, *err)
+	if *err == nil && treeOperations[o.op] {
+		metrics.SetTreeSize(o.r.LeavesSet())
+	}
+
+	attrs := o.attrs
+	if *err == nil && len(results) != 0 && o.r.logger != nil {
+		attrs = append(attrs[:len(attrs):len(attrs)], results...)
+	}
+	o.r.logOperation(o.op, duration, *err, attrs)
+
+	if o.span != nil {
+		if *err != nil {
+			o.span.RecordError(*err)
+		} else {
+			setSpanAttributes(o.span, results)
+		}
+		o.span.End()
+	}
+}
+
+// track measures an operation without a parent span. It is used as `defer r.track(op, attrs...)(&err)`,
+// with err being the named error result of the method, so the outcome is known when the method returns
+func (r *RLN) track(op Operation, attrs ...any) func(err *error) {
+	o := r.startOperation(context.Background(), op, attrs...)
+	return func(err *error) {
+		o.end(err)
+	}
+}
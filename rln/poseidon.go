@@ -0,0 +1,33 @@
+package rln
+
+import (
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/iden3/go-iden3-crypto/poseidon"
+)
+
+// toFieldElement interprets a little endian 32 byte value as an element of the BN254
+// scalar field. Values larger than the modulus are reduced, the same way zerokit does
+// when deserializing field elements
+func toFieldElement(value [32]byte) *big.Int {
+	result := Bytes32ToBigInt(value)
+	return result.Mod(result, fr.Modulus())
+}
+
+// poseidonHash computes the Poseidon hash of the inputs without requiring a RLN instance.
+// The inputs and output are field elements serialized as 32 bytes in little endian.
+// Equivalent to: https://github.com/vacp2p/zerokit/blob/v0.3.5/rln/src/hashers.rs
+func poseidonHash(input ...[32]byte) (MerkleNode, error) {
+	elements := make([]*big.Int, len(input))
+	for i, in := range input {
+		elements[i] = toFieldElement(in)
+	}
+
+	h, err := poseidon.Hash(elements)
+	if err != nil {
+		return MerkleNode{}, err
+	}
+
+	return BigIntToBytes32(h), nil
+}
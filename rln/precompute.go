@@ -0,0 +1,218 @@
+package rln
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PrecomputerConfig describes the proofs generated by a Precomputer
+type PrecomputerConfig struct {
+	// Credential and Index identify the membership used for the proofs
+	Credential IdentityCredential
+	Index      MembershipIndex
+	// Signal returns the message that will be sent in the epoch
+	Signal func(epoch Epoch) []byte
+	// Ahead is the number of epochs after the current one that are precomputed
+	Ahead int
+	// IsAcceptableRoot indicates whether proofs generated against the root are still accepted
+	// by the verifiers. If nil, the root history of the instance is used when enabled, and
+	// otherwise only the current root of its tree is accepted
+	IsAcceptableRoot func(root MerkleNode) bool
+	// CurrentEpoch returns the epoch messages are sent in. If nil, GetCurrentEpoch is used
+	CurrentEpoch func() Epoch
+}
+
+// Precomputer generates in advance the proofs for the upcoming epochs of a member whose signals
+// are known ahead, i.e. heartbeats or scheduled messages, so the proof is ready when the message
+// is sent. Proofs are generated against the root of the tree at that time, and are discarded once
+// the root is no longer acceptable. It is safe for concurrent use
+type Precomputer struct {
+	prover  *Prover
+	config  PrecomputerConfig
+	refresh chan struct{}
+
+	// genMu serializes the calls to Precompute
+	genMu sync.Mutex
+
+	mu     sync.Mutex
+	proofs map[Epoch]*RateLimitProof
+}
+
+// NewPrecomputer creates a Precomputer that generates the proofs with `rln`, obtaining the Merkle
+// path of the membership from `provider`
+func NewPrecomputer(rln *RLN, provider MerkleProofProvider, config PrecomputerConfig) (*Precomputer, error) {
+	if config.Signal == nil {
+		return nil, errors.New("a signal function is required")
+	}
+	if config.Ahead < 0 {
+		return nil, errors.New("the number of epochs ahead cannot be negative")
+	}
+
+	if config.IsAcceptableRoot == nil {
+		switch {
+		case rln.rootHistory != nil:
+			config.IsAcceptableRoot = rln.IsRecentRoot
+		case rln.hasTree():
+			config.IsAcceptableRoot = func(root MerkleNode) bool {
+				current, err := rln.GetMerkleRoot()
+				return err == nil && current == root
+			}
+		default:
+			return nil, errors.New("IsAcceptableRoot is required for instances without a tree")
+		}
+	}
+
+	if config.CurrentEpoch == nil {
+		config.CurrentEpoch = GetCurrentEpoch
+	}
+
+	return &Precomputer{
+		prover:  NewProver(rln, provider),
+		config:  config,
+		refresh: make(chan struct{}, 1),
+		proofs:  make(map[Epoch]*RateLimitProof),
+	}, nil
+}
+
+// Precompute generates the proofs for the current epoch and the next ones that are missing,
+// after discarding the proofs of past epochs and those whose root is no longer acceptable.
+// A failure does not stop the generation of the proofs of the remaining epochs, and the error
+// of the first failed epoch is returned. It is reported as OpPrecompute
+func (p *Precomputer) Precompute() error {
+	return p.precompute(context.Background())
+}
+
+// precompute is Precompute with the span of the operation being a child of the span in ctx
+func (p *Precomputer) precompute(ctx context.Context) (err error) {
+	p.genMu.Lock()
+	defer p.genMu.Unlock()
+
+	current := p.config.CurrentEpoch()
+	op := p.prover.rln.startOperation(ctx, OpPrecompute, "epoch", current.Uint64(), "ahead", p.config.Ahead)
+	defer op.end(&err)
+
+	p.prune(current)
+
+	failed := 0
+	var firstErr error
+	for i := 0; i <= p.config.Ahead; i++ {
+		epoch := ToEpoch(current.Uint64() + uint64(i))
+		if _, ok := p.get(epoch); ok {
+			continue
+		}
+
+		if _, err := p.generate(op.ctx, epoch); err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = fmt.Errorf("epoch %d: %w", epoch.Uint64(), err)
+			}
+		}
+	}
+
+	if firstErr != nil {
+		return fmt.Errorf("could not precompute %d of %d epochs: %w", failed, p.config.Ahead+1, firstErr)
+	}
+	return nil
+}
+
+// Proof returns the proof for the epoch and the signal returned by PrecomputerConfig.Signal.
+// The precomputed proof is used if its root is still acceptable, otherwise it is generated
+func (p *Precomputer) Proof(epoch Epoch) (*RateLimitProof, error) {
+	proof, ok := p.get(epoch)
+	if !ok {
+		var err error
+		proof, err = p.generate(context.Background(), epoch)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := *proof
+	return &result, nil
+}
+
+// Refresh discards the proofs whose root is no longer acceptable and wakes up Run to replace
+// them. It should be called whenever the tree changes
+func (p *Precomputer) Refresh() {
+	p.prune(p.config.CurrentEpoch())
+
+	select {
+	case p.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Run calls Precompute when started, at the start of every epoch of GetCurrentEpoch and after
+// every Refresh, until the context is cancelled. Failures are reported by Precompute as
+// OpPrecompute to the Metrics and Logger of the instance, and retried in the next epoch. Their
+// spans are children of the span in ctx
+func (p *Precomputer) Run(ctx context.Context) error {
+	timer := time.NewTimer(untilNextEpoch(time.Now()))
+	defer timer.Stop()
+
+	for {
+		// The error was already reported
+		_ = p.precompute(ctx)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			timer.Reset(untilNextEpoch(time.Now()))
+		case <-p.refresh:
+		}
+	}
+}
+
+// untilNextEpoch returns the time from `now` until the start of the next epoch
+func untilNextEpoch(now time.Time) time.Duration {
+	next := ToEpoch(CalcEpoch(now).Uint64() + 1)
+	return next.Time().Sub(now)
+}
+
+// generate creates the proof for the epoch and stores it
+func (p *Precomputer) generate(ctx context.Context, epoch Epoch) (*RateLimitProof, error) {
+	proof, err := p.prover.GenerateProofContext(ctx, p.config.Signal(epoch), p.config.Credential, p.config.Index, epoch)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.proofs[epoch] = proof
+	p.mu.Unlock()
+
+	return proof, nil
+}
+
+// get returns the stored proof for the epoch, if its root is still acceptable
+func (p *Precomputer) get(epoch Epoch) (*RateLimitProof, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	proof, ok := p.proofs[epoch]
+	if !ok {
+		return nil, false
+	}
+
+	if !p.config.IsAcceptableRoot(proof.MerkleRoot) {
+		delete(p.proofs, epoch)
+		return nil, false
+	}
+
+	return proof, true
+}
+
+// prune removes the proofs of epochs before `current` and those whose root is no longer acceptable
+func (p *Precomputer) prune(current Epoch) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for epoch, proof := range p.proofs {
+		if Diff(epoch, current) < 0 || !p.config.IsAcceptableRoot(proof.MerkleRoot) {
+			delete(p.proofs, epoch)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package rln
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func (s *RLNSuite) TestPrecomputer() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	memKeys, err := rln.MembershipKeyGen()
+	s.NoError(err)
+
+	err = rln.InsertMember(memKeys.IDCommitment)
+	s.NoError(err)
+
+	signal := func(epoch Epoch) []byte {
+		return []byte(fmt.Sprintf("heartbeat %d", epoch.Uint64()))
+	}
+
+	var current atomic.Uint64
+	current.Store(1000)
+
+	var generated atomic.Int32
+	provider := MerkleProofProviderFunc(func(index MembershipIndex) (MerkleProof, error) {
+		generated.Add(1)
+		return rln.GetMerkleProof(index)
+	})
+
+	_, err = NewPrecomputer(rln, provider, PrecomputerConfig{Credential: *memKeys})
+	s.Error(err)
+
+	precomputer, err := NewPrecomputer(rln, provider, PrecomputerConfig{
+		Credential:   *memKeys,
+		Index:        0,
+		Signal:       signal,
+		Ahead:        1,
+		CurrentEpoch: func() Epoch { return ToEpoch(current.Load()) },
+	})
+	s.NoError(err)
+
+	err = precomputer.Precompute()
+	s.NoError(err)
+	s.Equal(int32(2), generated.Load())
+
+	root, err := rln.GetMerkleRoot()
+	s.NoError(err)
+
+	// The proofs are handed out without generating them again
+	for _, epoch := range []Epoch{ToEpoch(1000), ToEpoch(1001)} {
+		proof, err := precomputer.Proof(epoch)
+		s.NoError(err)
+		s.Equal(epoch, proof.Epoch)
+		s.Equal(root, proof.MerkleRoot)
+
+		verified, err := rln.Verify(signal(epoch), *proof, root)
+		s.NoError(err)
+		s.True(verified)
+	}
+	s.Equal(int32(2), generated.Load())
+
+	// Only the missing epoch is generated when time advances
+	current.Store(1001)
+	err = precomputer.Precompute()
+	s.NoError(err)
+	s.Equal(int32(3), generated.Load())
+
+	// Changing the tree discards the proofs generated against the previous root
+	err = rln.InsertMember(IDCommitment{1})
+	s.NoError(err)
+
+	newRoot, err := rln.GetMerkleRoot()
+	s.NoError(err)
+
+	proof, err := precomputer.Proof(ToEpoch(1001))
+	s.NoError(err)
+	s.Equal(newRoot, proof.MerkleRoot)
+	s.Equal(int32(4), generated.Load())
+
+	// Run replaces the rest of the discarded proofs
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- precomputer.Run(ctx) }()
+
+	s.Eventually(func() bool { return generated.Load() == 5 }, 30*time.Second, 10*time.Millisecond)
+
+	cancel()
+	s.ErrorIs(<-done, context.Canceled)
+
+	proof, err = precomputer.Proof(ToEpoch(1002))
+	s.NoError(err)
+	s.Equal(newRoot, proof.MerkleRoot)
+	s.Equal(int32(5), generated.Load())
+}
+
+func (s *RLNSuite) TestPrecomputerFailures() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	memKeys, err := rln.MembershipKeyGen()
+	s.NoError(err)
+
+	err = rln.InsertMember(memKeys.IDCommitment)
+	s.NoError(err)
+
+	metrics := &recordingMetrics{}
+	rln.SetMetrics(metrics)
+
+	// The path of the first epoch cannot be obtained
+	var calls atomic.Int32
+	providerErr := errors.New("provider unavailable")
+	provider := MerkleProofProviderFunc(func(index MembershipIndex) (MerkleProof, error) {
+		if calls.Add(1) == 1 {
+			return MerkleProof{}, providerErr
+		}
+		return rln.GetMerkleProof(index)
+	})
+
+	precomputer, err := NewPrecomputer(rln, provider, PrecomputerConfig{
+		Credential:   *memKeys,
+		Signal:       func(epoch Epoch) []byte { return epoch[:] },
+		Ahead:        2,
+		CurrentEpoch: func() Epoch { return ToEpoch(1000) },
+	})
+	s.NoError(err)
+
+	// The remaining epochs are generated anyway
+	err = precomputer.Precompute()
+	s.ErrorIs(err, providerErr)
+	s.Equal(int32(3), calls.Load())
+
+	for _, epoch := range []Epoch{ToEpoch(1001), ToEpoch(1002)} {
+		_, err := precomputer.Proof(epoch)
+		s.NoError(err)
+	}
+	s.Equal(int32(3), calls.Load())
+
+	s.Contains(metrics.operations, observedOperation{op: OpPrecompute, failed: true})
+
+	// Only the failed epoch is retried
+	err = precomputer.Precompute()
+	s.NoError(err)
+	s.Equal(int32(4), calls.Load())
+	s.Equal(observedOperation{op: OpPrecompute}, metrics.operations[len(metrics.operations)-1])
+}
+
+func TestUntilNextEpoch(t *testing.T) {
+	epochStart := ToEpoch(1000).Time()
+	epochLength := time.Duration(EPOCH_UNIT_SECONDS) * time.Second
+
+	require.Equal(t, epochLength, untilNextEpoch(epochStart))
+	require.Equal(t, epochLength-250*time.Millisecond, untilNextEpoch(epochStart.Add(250*time.Millisecond)))
+}
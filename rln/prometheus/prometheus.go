@@ -0,0 +1,84 @@
+// Package prometheus exports the measurements of RLN instances as Prometheus metrics
+//
+//	metrics, err := prometheus.New(prom.DefaultRegisterer)
+//	...
+//	r.SetMetrics(metrics)
+package prometheus
+
+import (
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/waku-org/go-zerokit-rln/rln"
+)
+
+const namespace = "rln"
+
+// Metrics implements rln.Metrics with Prometheus collectors
+type Metrics struct {
+	duration *prom.HistogramVec
+	failures *prom.CounterVec
+	leaves   prom.Gauge
+	cache    *prom.CounterVec
+}
+
+var _ rln.Metrics = (*Metrics)(nil)
+var _ rln.VerifyCacheMetrics = (*Metrics)(nil)
+
+// New creates the collectors and registers them in `registerer`. A single Metrics can be
+// shared by several RLN instances, in which case the tree size is the one of the last
+// modified tree
+func New(registerer prom.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		duration: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of the RLN operations",
+			// From 100µs for tree operations up to ~26s for proofs in slow devices
+			Buckets: prom.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"operation"}),
+		failures: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "operation_failures_total",
+			Help:      "Number of RLN operations that returned an error",
+		}, []string{"operation"}),
+		leaves: prom.NewGauge(prom.GaugeOpts{
+			Namespace: namespace,
+			Name:      "tree_leaves",
+			Help:      "Number of leaves set in the RLN membership tree",
+		}),
+		cache: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "verify_cache_lookups_total",
+			Help:      "Number of lookups in the verified proof cache, by result",
+		}, []string{"result"}),
+	}
+
+	for _, c := range []prom.Collector{m.duration, m.failures, m.leaves, m.cache} {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Metrics) ObserveOperation(op rln.Operation, duration time.Duration, err error) {
+	m.duration.WithLabelValues(string(op)).Observe(duration.Seconds())
+	if err != nil {
+		m.failures.WithLabelValues(string(op)).Inc()
+	}
+}
+
+func (m *Metrics) SetTreeSize(leaves uint) {
+	m.leaves.Set(float64(leaves))
+}
+
+func (m *Metrics) ObserveVerifyCache(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cache.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,39 @@
+package prometheus
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/waku-org/go-zerokit-rln/rln"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := prom.NewRegistry()
+
+	m, err := New(registry)
+	require.NoError(t, err)
+
+	m.ObserveOperation(rln.OpVerify, 10*time.Millisecond, nil)
+	m.ObserveOperation(rln.OpVerify, 20*time.Millisecond, errors.New("failed"))
+	m.ObserveOperation(rln.OpFlush, time.Millisecond, nil)
+	m.SetTreeSize(42)
+	m.ObserveVerifyCache(true)
+	m.ObserveVerifyCache(true)
+	m.ObserveVerifyCache(false)
+
+	require.Equal(t, 2, testutil.CollectAndCount(m.duration))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.failures.WithLabelValues(string(rln.OpVerify))))
+	require.Equal(t, float64(0), testutil.ToFloat64(m.failures.WithLabelValues(string(rln.OpFlush))))
+	require.Equal(t, float64(42), testutil.ToFloat64(m.leaves))
+	require.Equal(t, float64(2), testutil.ToFloat64(m.cache.WithLabelValues("hit")))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.cache.WithLabelValues("miss")))
+
+	// The collectors can only be registered once
+	_, err = New(registry)
+	require.Error(t, err)
+}
@@ -0,0 +1,154 @@
+package rln
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MerkleProofProvider returns the Merkle proof for the element at the specified index.
+// It allows generating proofs without keeping a copy of the tree: the path can come
+// from the local tree (*RLN implements this interface), a remote service or a cache
+type MerkleProofProvider interface {
+	GetMerkleProof(index MembershipIndex) (MerkleProof, error)
+}
+
+// MerkleProofProviderFunc is an adapter to allow the use of ordinary functions, i.e.
+// a client of a remote service, as a MerkleProofProvider
+type MerkleProofProviderFunc func(index MembershipIndex) (MerkleProof, error)
+
+// GetMerkleProof calls f(index)
+func (f MerkleProofProviderFunc) GetMerkleProof(index MembershipIndex) (MerkleProof, error) {
+	return f(index)
+}
+
+// MerkleProofProviderContext can be implemented by a MerkleProofProvider to receive the context
+// of the proof being generated, i.e. to cancel a request to a remote service or to make its span
+// a child of the proof generation. *RLN and CachedMerkleProofProvider implement it
+type MerkleProofProviderContext interface {
+	GetMerkleProofContext(ctx context.Context, index MembershipIndex) (MerkleProof, error)
+}
+
+// getMerkleProof obtains the proof from `provider`, passing ctx if it accepts one
+func getMerkleProof(ctx context.Context, provider MerkleProofProvider, index MembershipIndex) (MerkleProof, error) {
+	if p, ok := provider.(MerkleProofProviderContext); ok {
+		return p.GetMerkleProofContext(ctx, index)
+	}
+	return provider.GetMerkleProof(index)
+}
+
+type cachedMerkleProof struct {
+	proof     MerkleProof
+	fetchedAt time.Time
+}
+
+// CachedMerkleProofProvider keeps the proofs returned by another MerkleProofProvider
+// for a limited amount of time. Since any change in the tree modifies the path of
+// every member, the cache should be invalidated whenever the root changes
+type CachedMerkleProofProvider struct {
+	mu       sync.Mutex
+	provider MerkleProofProvider
+	ttl      time.Duration
+	entries  map[MembershipIndex]cachedMerkleProof
+}
+
+// NewCachedMerkleProofProvider creates a CachedMerkleProofProvider that keeps proofs
+// obtained from `provider` for `ttl`
+func NewCachedMerkleProofProvider(provider MerkleProofProvider, ttl time.Duration) *CachedMerkleProofProvider {
+	return &CachedMerkleProofProvider{
+		provider: provider,
+		ttl:      ttl,
+		entries:  make(map[MembershipIndex]cachedMerkleProof),
+	}
+}
+
+// GetMerkleProof returns the cached proof for the index, fetching it if it is missing or expired
+func (c *CachedMerkleProofProvider) GetMerkleProof(index MembershipIndex) (MerkleProof, error) {
+	return c.GetMerkleProofContext(context.Background(), index)
+}
+
+// GetMerkleProofContext is like GetMerkleProof, but ctx is passed to the underlying provider
+func (c *CachedMerkleProofProvider) GetMerkleProofContext(ctx context.Context, index MembershipIndex) (MerkleProof, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[index]
+	c.mu.Unlock()
+
+	if ok && time.Since(entry.fetchedAt) < c.ttl {
+		return copyMerkleProof(entry.proof), nil
+	}
+
+	proof, err := getMerkleProof(ctx, c.provider, index)
+	if err != nil {
+		return MerkleProof{}, err
+	}
+
+	c.mu.Lock()
+	c.entries[index] = cachedMerkleProof{
+		proof:     copyMerkleProof(proof),
+		fetchedAt: time.Now(),
+	}
+	c.mu.Unlock()
+
+	return proof, nil
+}
+
+// Invalidate removes all the cached proofs
+func (c *CachedMerkleProofProvider) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[MembershipIndex]cachedMerkleProof)
+}
+
+func copyMerkleProof(proof MerkleProof) MerkleProof {
+	result := MerkleProof{
+		PathElements: make([]MerkleNode, len(proof.PathElements)),
+		PathIndexes:  make([]uint8, len(proof.PathIndexes)),
+	}
+	copy(result.PathElements, proof.PathElements)
+	copy(result.PathIndexes, proof.PathIndexes)
+	return result
+}
+
+// Prover generates RLN proofs using the Merkle path obtained from a MerkleProofProvider,
+// so the RLN instance used for proving does not need to contain the tree
+type Prover struct {
+	rln      *RLN
+	provider MerkleProofProvider
+}
+
+// NewProver creates a Prover that uses `rln` to generate the proofs and `provider`
+// to obtain the Merkle path of the memberships
+func NewProver(rln *RLN, provider MerkleProofProvider) *Prover {
+	return &Prover{
+		rln:      rln,
+		provider: provider,
+	}
+}
+
+// GenerateProof generates a proof for the RLN given a KeyPair and its index in the
+// membership tree. The signal is hashed with the SignalHasher of the RLN instance
+func (p *Prover) GenerateProof(data []byte, key IdentityCredential, index MembershipIndex, epoch Epoch) (*RateLimitProof, error) {
+	return p.GenerateProofContext(context.Background(), data, key, index, epoch)
+}
+
+// GenerateProofContext is like GenerateProof, but ctx is passed to the provider if it implements
+// MerkleProofProviderContext, and the span of the proof generation is a child of the span in ctx
+func (p *Prover) GenerateProofContext(ctx context.Context, data []byte, key IdentityCredential, index MembershipIndex, epoch Epoch) (*RateLimitProof, error) {
+	merkleProof, err := getMerkleProof(ctx, p.provider, index)
+	if err != nil {
+		return nil, fmt.Errorf("could not obtain the merkle proof: %w", err)
+	}
+
+	// A malformed path would still produce a proof, which would fail verification
+	if err := merkleProof.ValidateDepth(p.rln.depth); err != nil {
+		return nil, err
+	}
+
+	witness, err := CreateWitnessWithHasher(p.rln.getSignalHasher(), key.IDSecretHash, data, epoch, merkleProof)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.rln.GenerateRLNProofWithWitnessContext(ctx, witness)
+}
@@ -0,0 +1,108 @@
+package rln
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedMerkleProofProvider(t *testing.T) {
+	calls := 0
+	provider := MerkleProofProviderFunc(func(index MembershipIndex) (MerkleProof, error) {
+		calls++
+		if index == 99 {
+			return MerkleProof{}, errors.New("not found")
+		}
+		return MerkleProof{
+			PathElements: []MerkleNode{{byte(index)}},
+			PathIndexes:  []uint8{0},
+		}, nil
+	})
+
+	cache := NewCachedMerkleProofProvider(provider, time.Hour)
+
+	proof, err := cache.GetMerkleProof(1)
+	require.NoError(t, err)
+	require.Equal(t, MerkleNode{1}, proof.PathElements[0])
+	require.Equal(t, 1, calls)
+
+	// Modifying a returned proof does not alter the cache
+	proof.PathElements[0] = MerkleNode{0xff}
+
+	proof, err = cache.GetMerkleProof(1)
+	require.NoError(t, err)
+	require.Equal(t, MerkleNode{1}, proof.PathElements[0])
+	require.Equal(t, 1, calls)
+
+	_, err = cache.GetMerkleProof(99)
+	require.Error(t, err)
+	require.Equal(t, 2, calls)
+
+	cache.Invalidate()
+	_, err = cache.GetMerkleProof(1)
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+
+	expiring := NewCachedMerkleProofProvider(provider, 0)
+	_, err = expiring.GetMerkleProof(1)
+	require.NoError(t, err)
+	_, err = expiring.GetMerkleProof(1)
+	require.NoError(t, err)
+	require.Equal(t, 5, calls)
+}
+
+func (s *RLNSuite) TestProverWithoutLocalTree() {
+	// Node with the full tree, acting as the source of the merkle proofs
+	fullNode, err := NewRLN()
+	s.NoError(err)
+
+	var memKeys *IdentityCredential
+	for i := 0; i < 5; i++ {
+		keys, err := fullNode.MembershipKeyGen()
+		s.NoError(err)
+
+		err = fullNode.InsertMember(keys.IDCommitment)
+		s.NoError(err)
+
+		if i == 3 {
+			memKeys = keys
+		}
+	}
+
+	root, err := fullNode.GetMerkleRoot()
+	s.NoError(err)
+
+	// Light node, whose tree is empty
+	lightNode, err := NewRLN()
+	s.NoError(err)
+
+	prover := NewProver(lightNode, NewCachedMerkleProofProvider(fullNode, time.Minute))
+
+	msg := []byte("Hello")
+	proofRes, err := prover.GenerateProof(msg, *memKeys, MembershipIndex(3), ToEpoch(1000))
+	s.NoError(err)
+	s.Equal(root, proofRes.MerkleRoot)
+
+	verified, err := fullNode.Verify(msg, *proofRes, root)
+	s.NoError(err)
+	s.True(verified)
+
+	// Failures of the provider are reported
+	failing := NewProver(lightNode, MerkleProofProviderFunc(func(index MembershipIndex) (MerkleProof, error) {
+		return MerkleProof{}, errors.New("service unavailable")
+	}))
+	_, err = failing.GenerateProof(msg, *memKeys, MembershipIndex(3), ToEpoch(1000))
+	s.Error(err)
+
+	// Paths that do not match the tree depth are rejected before proving
+	shallow := NewProver(lightNode, MerkleProofProviderFunc(func(index MembershipIndex) (MerkleProof, error) {
+		return MerkleProof{
+			PathElements: make([]MerkleNode, TreeDepth15),
+			PathIndexes:  make([]uint8, TreeDepth15),
+		}, nil
+	}))
+	_, err = shallow.GenerateProof(msg, *memKeys, MembershipIndex(3), ToEpoch(1000))
+	s.Error(err)
+}
@@ -0,0 +1,22 @@
+package rln
+
+import (
+	"embed"
+	"fmt"
+	"path"
+)
+
+// Verification keys of the circuits bundled in zerokit, so proofs can be verified on the Go side.
+// Copied from https://github.com/vacp2p/zerokit/tree/v0.3.5/rln/resources
+//
+//go:embed resources/tree_height_*/verification_key.json
+var resources embed.FS
+
+// builtinVerifyingKey returns the verification key zerokit uses for the circuit of the specified depth
+func builtinVerifyingKey(depth TreeDepth) ([]byte, error) {
+	b, err := resources.ReadFile(path.Join("resources", getResourcesFolder(depth), "verification_key.json"))
+	if err != nil {
+		return nil, fmt.Errorf("no verifying key available for tree depth %d: %w", depth, err)
+	}
+	return b, nil
+}
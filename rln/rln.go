@@ -2,10 +2,13 @@ package rln
 
 import "C"
 import (
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 
 	"github.com/waku-org/go-zerokit-rln/rln/link"
 )
@@ -14,9 +17,45 @@ import (
 // Prevents a RLN ZK proof generated for one application to be re-used in another one.
 var RLN_IDENTIFIER = [32]byte{166, 140, 43, 8, 8, 22, 206, 113, 151, 128, 118, 40, 119, 197, 218, 174, 11, 117, 84, 228, 96, 211, 212, 140, 145, 104, 146, 99, 24, 192, 217, 4}
 
+// ErrTreeFull is returned when inserting members beyond the capacity of the tree, 2^depth
+var ErrTreeFull = errors.New("tree is full")
+
+// ErrUnsupported is returned by the instances created with NewVerifier or NewLazyProver
+// for the operations that require the tree, or the proving key in a verifier
+var ErrUnsupported = errors.New("operation not supported by this instance")
+
 // RLN represents the context used for rln.
 type RLN struct {
 	w *link.RLNWrapper
+
+	// writeMu serializes the modifications of the tree, so Backup can copy it in a consistent state
+	writeMu sync.Mutex
+
+	depth    TreeDepth
+	verifKey []byte
+	treePath string
+
+	vkOnce sync.Once
+	vk     *verifyingKey
+	vkErr  error
+
+	signalHasher SignalHasher
+	metrics      Metrics
+	logger       Logger
+	tracer       Tracer
+	verifyCache  *VerifyCache
+
+	// Only set in instances created with NewLazyProver or the WithLazyLoading option
+	lazy         *lazyLoader
+	merkleProofs MerkleProofProvider
+
+	leafCount   leafCount
+	memberIndex atomic.Pointer[memberIndex]
+	journal     *journal
+	rootHistory *rootHistory
+	lock        *treeLock
+	// closed is set by Close while holding writeMu
+	closed atomic.Bool
 }
 
 func getResourcesFolder(depth TreeDepth) string {
@@ -32,11 +71,18 @@ func NewRLN() (*RLN, error) {
 // NewRLNWithParams generates an instance of RLN. An instance supports both zkSNARKs logics
 // and Merkle tree data structure and operations. The parameter `depth“ indicates the depth of Merkle tree
 func NewRLNWithParams(depth int, wasm []byte, zkey []byte, verifKey []byte, treeConfig *TreeConfig) (*RLN, error) {
-	r := &RLN{}
+	r := &RLN{
+		depth:    TreeDepth(depth),
+		verifKey: verifKey,
+	}
 	var err error
 
 	treeConfigBytes := []byte{}
 	if treeConfig != nil {
+		if err := treeConfig.Validate(); err != nil {
+			return nil, err
+		}
+		r.treePath = treeConfig.Path
 		treeConfigBytes, err = json.Marshal(treeConfig)
 		if err != nil {
 			return nil, err
@@ -47,16 +93,41 @@ func NewRLNWithParams(depth int, wasm []byte, zkey []byte, verifKey []byte, tree
 	if err != nil {
 		return nil, err
 	}
+	r.leafCount.known = r.w.LeavesSet() == 0
 
 	return r, nil
 }
 
 // NewWithConfig generates an instance of RLN. An instance supports both zkSNARKs logics
 // and Merkle tree data structure and operations. The parameter `depth` indicates the depth of Merkle tree
-func NewWithConfig(depth TreeDepth, treeConfig *TreeConfig) (*RLN, error) {
-	r := &RLN{}
+func NewWithConfig(depth TreeDepth, treeConfig *TreeConfig, opts ...Option) (*RLN, error) {
+	r := &RLN{
+		depth: depth,
+	}
+	if treeConfig != nil {
+		if err := treeConfig.Validate(); err != nil {
+			return nil, err
+		}
+		r.treePath = treeConfig.Path
+	}
 	var err error
 
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.lazy {
+		// Fails early if the circuit of this depth is not bundled
+		if _, err := r.verifyingKey(); err != nil {
+			return nil, err
+		}
+		r.lazy, err = newLazyLoader(depth, treeConfig, true)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+
 	configBytes, err := json.Marshal(config{
 		ResourcesFolder: getResourcesFolder(depth),
 		TreeConfig:      treeConfig,
@@ -69,25 +140,112 @@ func NewWithConfig(depth TreeDepth, treeConfig *TreeConfig) (*RLN, error) {
 	if err != nil {
 		return nil, err
 	}
+	r.leafCount.known = r.w.LeavesSet() == 0
 
 	return r, nil
 }
 
-func (r *RLN) SetTree(treeHeight uint) error {
+// verifyingKey returns the key used to verify proofs on the Go side. It is the one
+// received in NewRLNWithParams, or the one bundled in zerokit for the tree depth
+func (r *RLN) verifyingKey() (*verifyingKey, error) {
+	r.vkOnce.Do(func() {
+		verifKey := r.verifKey
+		if len(verifKey) == 0 {
+			verifKey, r.vkErr = builtinVerifyingKey(r.depth)
+			if r.vkErr != nil {
+				return
+			}
+		}
+		r.vk, r.vkErr = parseVerifyingKey(verifKey)
+	})
+	return r.vk, r.vkErr
+}
+
+func (r *RLN) SetTree(treeHeight uint) (err error) {
+	if err := r.loadTree(); err != nil {
+		return err
+	}
+
+	if err := r.lockWrites(); err != nil {
+		return err
+	}
+	defer r.writeMu.Unlock()
+	defer r.track(OpSetTree, "tree_height", treeHeight)(&err)
+
 	success := r.w.SetTree(treeHeight)
 	if !success {
 		return errors.New("could not set tree height")
 	}
+	r.leafCount = leafCount{known: true}
+	r.recordRoot()
+	// zerokit replaces the tree with one stored in a temporary location
+	r.treePath = ""
+	if members := r.memberIndex.Load(); members != nil {
+		members.reset()
+	}
 	return nil
 }
 
 // Initialize merkle tree with a list of IDCommitments
-func (r *RLN) InitTreeWithMembers(idComms []IDCommitment) error {
+func (r *RLN) InitTreeWithMembers(idComms []IDCommitment) (err error) {
+	if err := r.loadTree(); err != nil {
+		return err
+	}
+
+	if err := r.lockWrites(); err != nil {
+		return err
+	}
+	defer r.writeMu.Unlock()
+	defer r.track(OpInitTreeWithMembers, "count", len(idComms))(&err)
+
 	idCommBytes := serializeCommitments(idComms)
 	initSuccess := r.w.InitTreeWithLeaves(idCommBytes)
 	if !initSuccess {
 		return errors.New("could not init tree")
 	}
+	r.leafCount = leafCount{known: true}
+	for _, idComm := range idComms {
+		if idComm != zeroLeaf {
+			r.leafCount.nonZero++
+		}
+	}
+	r.recordRoot()
+	r.treePath = ""
+	if members := r.memberIndex.Load(); members != nil {
+		members.reset()
+		for i, idComm := range idComms {
+			members.set(MembershipIndex(i), idComm)
+		}
+	}
+	return nil
+}
+
+// setLeavesFrom sets multiple leaves starting from index. Unlike InitTreeWithMembers
+// it does not reset the tree
+func (r *RLN) setLeavesFrom(index MembershipIndex, idComms []IDCommitment) (err error) {
+	if err := r.loadTree(); err != nil {
+		return err
+	}
+
+	if err := r.lockWrites(); err != nil {
+		return err
+	}
+	defer r.writeMu.Unlock()
+	defer r.track(OpSetLeaves, "index", index, "count", len(idComms))(&err)
+
+	applyLeafCount := r.countLeafChanges(index, idComms, nil)
+	idCommBytes := serializeCommitments(idComms)
+	success := r.w.SetLeavesFrom(index, idCommBytes)
+	if !success {
+		return errors.New("could not set leaves")
+	}
+	applyLeafCount()
+	r.recordRoot()
+	if members := r.memberIndex.Load(); members != nil {
+		for i, idComm := range idComms {
+			members.set(index+MembershipIndex(i), idComm)
+		}
+	}
 	return nil
 }
 
@@ -113,7 +271,13 @@ func toIdentityCredential(generatedKeys []byte) (*IdentityCredential, error) {
 
 // MembershipKeyGen generates a IdentityCredential that can be used for the
 // registration into the rln membership contract. Returns an error if the key generation fails
-func (r *RLN) MembershipKeyGen() (*IdentityCredential, error) {
+func (r *RLN) MembershipKeyGen() (_ *IdentityCredential, err error) {
+	if err := r.loadTree(); err != nil {
+		return nil, err
+	}
+
+	defer r.track(OpKeyGen)(&err)
+
 	generatedKeys := r.w.ExtendedKeyGen()
 	if generatedKeys == nil {
 		return nil, errors.New("error in key generation")
@@ -124,7 +288,13 @@ func (r *RLN) MembershipKeyGen() (*IdentityCredential, error) {
 // SeededMembershipKeyGen generates a deterministic IdentityCredential using a seed
 // that can be used for the registration into the rln membership contract.
 // Returns an error if the key generation fails
-func (r *RLN) SeededMembershipKeyGen(seed []byte) (*IdentityCredential, error) {
+func (r *RLN) SeededMembershipKeyGen(seed []byte) (_ *IdentityCredential, err error) {
+	if err := r.loadTree(); err != nil {
+		return nil, err
+	}
+
+	defer r.track(OpKeyGen)(&err)
+
 	generatedKeys := r.w.ExtendedSeededKeyGen(seed)
 	if generatedKeys == nil {
 		return nil, errors.New("error in key generation")
@@ -148,7 +318,13 @@ func appendLength32(input []byte) []byte {
 	return append(inputLen, input...)
 }
 
-func (r *RLN) Sha256(data []byte) (MerkleNode, error) {
+func (r *RLN) Sha256(data []byte) (_ MerkleNode, err error) {
+	if err := r.loadTree(); err != nil {
+		return MerkleNode{}, err
+	}
+
+	defer r.track(OpSha256, "size", len(data))(&err)
+
 	lenPrefData := appendLength(data)
 
 	b, err := r.w.Hash(lenPrefData)
@@ -163,6 +339,16 @@ func (r *RLN) Sha256(data []byte) (MerkleNode, error) {
 }
 
 func (r *RLN) Poseidon(input ...[]byte) (MerkleNode, error) {
+	return r.poseidon(context.Background(), input...)
+}
+
+func (r *RLN) poseidon(ctx context.Context, input ...[]byte) (_ MerkleNode, err error) {
+	if err := r.loadTree(); err != nil {
+		return MerkleNode{}, err
+	}
+
+	defer r.startOperation(ctx, OpPoseidon, "inputs", len(input)).end(&err)
+
 	data := serializeSlice(input)
 
 	inputLen := make([]byte, 8)
@@ -182,7 +368,20 @@ func (r *RLN) Poseidon(input ...[]byte) (MerkleNode, error) {
 }
 
 func (r *RLN) ExtractMetadata(proof RateLimitProof) (ProofMetadata, error) {
-	externalNullifierRes, err := r.Poseidon(proof.Epoch[:], proof.RLNIdentifier[:])
+	return r.ExtractMetadataContext(context.Background(), proof)
+}
+
+// ExtractMetadataContext is like ExtractMetadata, but its span is a child of the span in ctx
+func (r *RLN) ExtractMetadataContext(ctx context.Context, proof RateLimitProof) (_ ProofMetadata, err error) {
+	op := r.startOperation(ctx, OpExtractMetadata)
+	defer op.end(&err)
+
+	var externalNullifierRes MerkleNode
+	if !r.treeLoaded() {
+		externalNullifierRes, err = poseidonHash(proof.Epoch, proof.RLNIdentifier)
+	} else {
+		externalNullifierRes, err = r.poseidon(op.ctx, proof.Epoch[:], proof.RLNIdentifier[:])
+	}
 	if err != nil {
 		return ProofMetadata{}, fmt.Errorf("could not construct the external nullifier: %w", err)
 	}
@@ -199,6 +398,31 @@ func (r *RLN) ExtractMetadata(proof RateLimitProof) (ProofMetadata, error) {
 // The output will containt the proof data and should be parsed as |proof<128>|root<32>|epoch<32>|share_x<32>|share_y<32>|nullifier<32>|
 // integers wrapped in <> indicate value sizes in bytes
 func (r *RLN) GenerateProof(data []byte, key IdentityCredential, index MembershipIndex, epoch Epoch) (*RateLimitProof, error) {
+	return r.GenerateProofContext(context.Background(), data, key, index, epoch)
+}
+
+// GenerateProofContext is like GenerateProof, but its span is a child of the span in ctx
+func (r *RLN) GenerateProofContext(ctx context.Context, data []byte, key IdentityCredential, index MembershipIndex, epoch Epoch) (_ *RateLimitProof, err error) {
+	if !r.hasTree() && (r.lazy == nil || r.merkleProofs == nil) {
+		return nil, ErrUnsupported
+	}
+	op := r.startOperation(ctx, OpGenerateProof, "index", index, "epoch", epoch.Uint64(), "signal_size", len(data))
+	defer op.end(&err)
+
+	if !r.hasTree() {
+		// Prover-only instance, the path is obtained from its provider
+		return NewProver(r, r.merkleProofs).GenerateProofContext(op.ctx, data, key, index, epoch)
+	}
+
+	if !r.usesZerokitHasher() {
+		// zerokit would hash the signal with Keccak256, so the witness is built here instead
+		return NewProver(r, r).GenerateProofContext(op.ctx, data, key, index, epoch)
+	}
+
+	if err := r.loadTree(); err != nil {
+		return nil, err
+	}
+
 	input := serialize(key.IDSecretHash, index, epoch, data)
 	proofBytes, err := r.w.GenerateRLNProof(input)
 	if err != nil {
@@ -248,8 +472,19 @@ func (r *RLN) GenerateProof(data []byte, key IdentityCredential, index Membershi
 // input [ id_secret_hash<32> | num_elements<8> | path_elements<var1> | num_indexes<8> | path_indexes<var2> | x<32> | epoch<32> | rln_identifier<32> ]
 // output [ proof<128> | root<32> | epoch<32> | share_x<32> | share_y<32> | nullifier<32> | rln_identifier<32> ]
 func (r *RLN) GenerateRLNProofWithWitness(witness RLNWitnessInput) (*RateLimitProof, error) {
+	return r.GenerateRLNProofWithWitnessContext(context.Background(), witness)
+}
+
+// GenerateRLNProofWithWitnessContext is like GenerateRLNProofWithWitness, but its span is a child
+// of the span in ctx
+func (r *RLN) GenerateRLNProofWithWitnessContext(ctx context.Context, witness RLNWitnessInput) (_ *RateLimitProof, err error) {
+	w, err := r.provingWrapper()
+	if err != nil {
+		return nil, err
+	}
+	defer r.startOperation(ctx, OpGenerateRLNProofWithWitness, "path_length", len(witness.MerkleProof.PathElements)).end(&err)
 
-	proofBytes, err := r.w.GenerateRLNProofWithWitness(witness.serialize())
+	proofBytes, err := w.GenerateRLNProofWithWitness(witness.serialize())
 	if err != nil {
 		return nil, err
 	}
@@ -293,12 +528,11 @@ func (r *RLN) GenerateRLNProofWithWitness(witness RLNWitnessInput) (*RateLimitPr
 
 }
 
-func serialize32(roots [][32]byte) []byte {
-	var result []byte
-	for _, r := range roots {
-		result = append(result, r[:]...)
+func appendRoots(dst []byte, roots [][32]byte) []byte {
+	for i := range roots {
+		dst = append(dst, roots[i][:]...)
 	}
-	return result
+	return dst
 }
 
 func serializeSlice(roots [][]byte) []byte {
@@ -344,19 +578,65 @@ func serializeIndices(indices []MembershipIndex) []byte {
 // validRoots should contain a sequence of roots in the acceptable windows.
 // As default, it is set to an empty sequence of roots. This implies that the validity check for the proof's root is skipped
 func (r *RLN) Verify(data []byte, proof RateLimitProof, roots ...[32]byte) (bool, error) {
-	proofBytes := proof.serializeWithData(data)
-	rootBytes := serialize32(roots)
+	return r.VerifyContext(context.Background(), data, proof, roots...)
+}
+
+// VerifyContext is like Verify, but its span is a child of the span in ctx
+func (r *RLN) VerifyContext(ctx context.Context, data []byte, proof RateLimitProof, roots ...[32]byte) (bool, error) {
+	buffers := verifyBufferPool.Get().(*verifyBuffers)
+	defer buffers.release()
+
+	return r.verify(ctx, buffers, data, &proof, roots)
+}
 
-	res, err := r.w.VerifyWithRoots(proofBytes, rootBytes)
+// verify serializes the input for zerokit in the reusable buffers
+func (r *RLN) verify(ctx context.Context, buffers *verifyBuffers, data []byte, proof *RateLimitProof, roots []MerkleNode) (valid bool, err error) {
+	op := r.startOperation(ctx, OpVerify)
+	if op.recording() {
+		op.describe("signal_size", len(data), "roots", len(roots))
+	}
+	defer func() { op.end(&err, "valid", valid) }()
+
+	buffers.proof = proof.appendWithData(buffers.proof[:0], data)
+	buffers.roots = appendRoots(buffers.roots[:0], roots)
+
+	cache := r.verifyCache
+	var key verifyCacheKey
+	if cache != nil {
+		key = buffers.cacheKey()
+		valid, ok := cache.get(key)
+		if metrics, isCacheMetrics := r.getMetrics().(VerifyCacheMetrics); isCacheMetrics {
+			metrics.ObserveVerifyCache(ok)
+		}
+		if ok {
+			return valid, nil
+		}
+	}
+
+	if !r.treeLoaded() || !r.usesZerokitHasher() {
+		valid, err = r.verifyWithSignalHasher(data, *proof, roots)
+	} else {
+		valid, err = r.w.VerifyWithRoots(buffers.proof, buffers.roots)
+	}
 	if err != nil {
 		return false, err
 	}
 
-	return res, nil
+	if cache != nil {
+		cache.add(key, valid)
+	}
+
+	return valid, nil
 }
 
 // RecoverIDSecret returns an IDSecret having obtained before two proofs
-func (r *RLN) RecoverIDSecret(proof1 RateLimitProof, proof2 RateLimitProof) (IDSecretHash, error) {
+func (r *RLN) RecoverIDSecret(proof1 RateLimitProof, proof2 RateLimitProof) (_ IDSecretHash, err error) {
+	if !r.treeLoaded() {
+		return recoverIDSecret(proof1, proof2)
+	}
+
+	defer r.track(OpRecoverIDSecret)(&err)
+
 	proof1Bytes := proof1.serialize()
 	proof2Bytes := proof2.serialize()
 	secret, err := r.w.RecoverIDSecret(proof1Bytes, proof2Bytes)
@@ -369,59 +649,153 @@ func (r *RLN) RecoverIDSecret(proof1 RateLimitProof, proof2 RateLimitProof) (IDS
 }
 
 // InsertMember adds the member to the tree
-func (r *RLN) InsertMember(idComm IDCommitment) error {
+func (r *RLN) InsertMember(idComm IDCommitment) (err error) {
+	if err := r.loadTree(); err != nil {
+		return err
+	}
+
+	if err := r.lockWrites(); err != nil {
+		return err
+	}
+	defer r.writeMu.Unlock()
+	defer r.track(OpInsertMember)(&err)
+
+	index := MembershipIndex(r.LeavesSet())
+	if uint64(index) >= r.capacity() {
+		return ErrTreeFull
+	}
+	applyLeafCount := r.countLeafChanges(index, []IDCommitment{idComm}, nil)
 	insertionSuccess := r.w.SetNextLeaf(idComm[:])
 	if !insertionSuccess {
 		return errors.New("could not insert member")
 	}
+	applyLeafCount()
+	r.recordRoot()
+	if members := r.memberIndex.Load(); members != nil {
+		members.set(index, idComm)
+	}
 	return nil
 }
 
 // Insert multiple members i.e., identity commitments starting from index
 // This proc is atomic, i.e., if any of the insertions fails, all the previous insertions are rolled back
-func (r *RLN) InsertMembers(index MembershipIndex, idComms []IDCommitment) error {
+func (r *RLN) InsertMembers(index MembershipIndex, idComms []IDCommitment) (err error) {
+	if err := r.loadTree(); err != nil {
+		return err
+	}
+
+	if err := r.lockWrites(); err != nil {
+		return err
+	}
+	defer r.writeMu.Unlock()
+	defer r.track(OpInsertMembers, "index", index, "count", len(idComms))(&err)
+
+	if uint64(index)+uint64(len(idComms)) > r.capacity() {
+		return ErrTreeFull
+	}
+	applyLeafCount := r.countLeafChanges(index, idComms, nil)
 	idCommBytes := serializeCommitments(idComms)
 	indicesBytes := serializeIndices(nil)
 	insertionSuccess := r.w.AtomicOperation(index, idCommBytes, indicesBytes)
 	if !insertionSuccess {
 		return errors.New("could not insert members")
 	}
+	applyLeafCount()
+	r.recordRoot()
+	if members := r.memberIndex.Load(); members != nil {
+		for i, idComm := range idComms {
+			members.set(index+MembershipIndex(i), idComm)
+		}
+	}
 	return nil
 }
 
 // Insert a member in the tree at specified index
-func (r *RLN) InsertMemberAt(index MembershipIndex, idComm IDCommitment) error {
+func (r *RLN) InsertMemberAt(index MembershipIndex, idComm IDCommitment) (err error) {
+	if err := r.loadTree(); err != nil {
+		return err
+	}
+
+	if err := r.lockWrites(); err != nil {
+		return err
+	}
+	defer r.writeMu.Unlock()
+	defer r.track(OpInsertMemberAt, "index", index)(&err)
+
+	applyLeafCount := r.countLeafChanges(index, []IDCommitment{idComm}, nil)
 	insertionSuccess := r.w.SetLeaf(index, idComm[:])
 	if !insertionSuccess {
 		return errors.New("could not insert member")
 	}
+	applyLeafCount()
+	r.recordRoot()
+	if members := r.memberIndex.Load(); members != nil {
+		members.set(index, idComm)
+	}
 	return nil
 }
 
 // DeleteMember removes an IDCommitment key from the tree. The index
 // parameter is the position of the id commitment key to be deleted from the tree.
 // The deleted id commitment key is replaced with a zero leaf
-func (r *RLN) DeleteMember(index MembershipIndex) error {
+func (r *RLN) DeleteMember(index MembershipIndex) (err error) {
+	if err := r.loadTree(); err != nil {
+		return err
+	}
+
+	if err := r.lockWrites(); err != nil {
+		return err
+	}
+	defer r.writeMu.Unlock()
+	defer r.track(OpDeleteMember, "index", index)(&err)
+
+	applyLeafCount := r.countLeafChanges(0, nil, []MembershipIndex{index})
 	deletionSuccess := r.w.DeleteLeaf(index)
 	if !deletionSuccess {
 		return errors.New("could not delete member")
 	}
+	applyLeafCount()
+	r.recordRoot()
+	if members := r.memberIndex.Load(); members != nil {
+		members.remove(index)
+	}
 	return nil
 }
 
 // Delete multiple members
-func (r *RLN) DeleteMembers(indices []MembershipIndex) error {
+func (r *RLN) DeleteMembers(indices []MembershipIndex) (err error) {
+	if err := r.loadTree(); err != nil {
+		return err
+	}
+
+	if err := r.lockWrites(); err != nil {
+		return err
+	}
+	defer r.writeMu.Unlock()
+	defer r.track(OpDeleteMembers, "count", len(indices))(&err)
+
+	applyLeafCount := r.countLeafChanges(0, nil, indices)
 	idCommBytes := serializeCommitments(nil)
 	indicesBytes := serializeIndices(indices)
 	insertionSuccess := r.w.AtomicOperation(0, idCommBytes, indicesBytes)
 	if !insertionSuccess {
 		return errors.New("could not insert members")
 	}
-	return nil
+	applyLeafCount()
+	r.recordRoot()
+	// The index is updated with the leaves actually stored in the tree, since zerokit might not
+	// remove all of them in a single operation
+	return r.syncIndex(indices...)
 }
 
 // GetMerkleRoot reads the Merkle Tree root after insertion
-func (r *RLN) GetMerkleRoot() (MerkleNode, error) {
+func (r *RLN) GetMerkleRoot() (_ MerkleNode, err error) {
+	if err := r.loadTree(); err != nil {
+		return MerkleNode{}, err
+	}
+
+	defer r.track(OpGetMerkleRoot)(&err)
+
 	b, err := r.w.GetRoot()
 	if err != nil {
 		return MerkleNode{}, err
@@ -438,7 +812,13 @@ func (r *RLN) GetMerkleRoot() (MerkleNode, error) {
 }
 
 // GetLeaf reads the value stored at some index in the Merkle Tree
-func (r *RLN) GetLeaf(index MembershipIndex) (IDCommitment, error) {
+func (r *RLN) GetLeaf(index MembershipIndex) (_ IDCommitment, err error) {
+	if err := r.loadTree(); err != nil {
+		return IDCommitment{}, err
+	}
+
+	defer r.track(OpGetLeaf, "index", index)(&err)
+
 	b, err := r.w.GetLeaf(index)
 	if err != nil {
 		return IDCommitment{}, err
@@ -461,6 +841,17 @@ func (r *RLN) GetLeaf(index MembershipIndex) (IDCommitment, error) {
 // A tree with depth 20 has 676 bytes = 8 + 32 * 20 + 8 + 20 * 1
 // Proof elements are stored as little endian
 func (r *RLN) GetMerkleProof(index MembershipIndex) (MerkleProof, error) {
+	return r.GetMerkleProofContext(context.Background(), index)
+}
+
+// GetMerkleProofContext is like GetMerkleProof, but its span is a child of the span in ctx
+func (r *RLN) GetMerkleProofContext(ctx context.Context, index MembershipIndex) (_ MerkleProof, err error) {
+	if err := r.loadTree(); err != nil {
+		return MerkleProof{}, err
+	}
+
+	defer r.startOperation(ctx, OpGetMerkleProof, "index", index).end(&err)
+
 	proofBytes, err := r.w.GetMerkleProof(index)
 	if err != nil {
 		return MerkleProof{}, err
@@ -534,7 +925,17 @@ func CreateMembershipList(n int) ([]IdentityCredential, MerkleNode, error) {
 }
 
 // SetMetadata stores serialized data
-func (r *RLN) SetMetadata(metadata []byte) error {
+func (r *RLN) SetMetadata(metadata []byte) (err error) {
+	if err := r.loadTree(); err != nil {
+		return err
+	}
+
+	if err := r.lockWrites(); err != nil {
+		return err
+	}
+	defer r.writeMu.Unlock()
+	defer r.track(OpSetMetadata, "size", len(metadata))(&err)
+
 	success := r.w.SetMetadata(metadata)
 	if !success {
 		return errors.New("could not set metadata")
@@ -543,31 +944,76 @@ func (r *RLN) SetMetadata(metadata []byte) error {
 }
 
 // GetMetadata returns the stored serialized metadata
-func (r *RLN) GetMetadata() ([]byte, error) {
+func (r *RLN) GetMetadata() (_ []byte, err error) {
+	if err := r.loadTree(); err != nil {
+		return nil, err
+	}
+
+	defer r.track(OpGetMetadata)(&err)
+
 	return r.w.GetMetadata()
 }
 
 // AtomicOperation can be used to insert and remove elements into the merkle tree
 func (r *RLN) AtomicOperation(index MembershipIndex, idCommsToInsert []IDCommitment, indicesToRemove []MembershipIndex) error {
+	if err := r.loadTree(); err != nil {
+		return err
+	}
+
+	if err := r.lockWrites(); err != nil {
+		return err
+	}
+	defer r.writeMu.Unlock()
+	return r.atomicOperation(index, idCommsToInsert, indicesToRemove)
+}
+
+// atomicOperation applies an AtomicOperation. The caller must hold writeMu
+func (r *RLN) atomicOperation(index MembershipIndex, idCommsToInsert []IDCommitment, indicesToRemove []MembershipIndex) (err error) {
+	defer r.track(OpAtomicOperation, "index", index, "inserts", len(idCommsToInsert), "removals", len(indicesToRemove))(&err)
+
+	applyLeafCount := r.countLeafChanges(index, idCommsToInsert, indicesToRemove)
 	idCommBytes := serializeCommitments(idCommsToInsert)
 	indicesBytes := serializeIndices(indicesToRemove)
 	execSuccess := r.w.AtomicOperation(index, idCommBytes, indicesBytes)
 	if !execSuccess {
 		return errors.New("could not execute atomic_operation")
 	}
+	applyLeafCount()
+	r.recordRoot()
+	if members := r.memberIndex.Load(); members != nil {
+		touched := append([]MembershipIndex(nil), indicesToRemove...)
+		for i := range idCommsToInsert {
+			touched = append(touched, index+MembershipIndex(i))
+		}
+		return r.syncIndex(touched...)
+	}
 	return nil
 }
 
 // Flush
-func (r *RLN) Flush() error {
+func (r *RLN) Flush() (err error) {
+	if err := r.loadTree(); err != nil {
+		return err
+	}
+
+	defer r.track(OpFlush)(&err)
+
 	success := r.w.Flush()
 	if !success {
 		return errors.New("cannot flush db")
 	}
+
+	// The root history is only written to disk here
+	if r.rootHistory != nil {
+		return r.saveRootHistory()
+	}
 	return nil
 }
 
 // LeavesSet indicates how many elements have been inserted in the merkle tree
 func (r *RLN) LeavesSet() uint {
+	if r.loadTree() != nil {
+		return 0
+	}
 	return r.w.LeavesSet()
 }
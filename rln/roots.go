@@ -0,0 +1,294 @@
+package rln
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RootRecord is a root the tree had after processing a block
+type RootRecord struct {
+	Root      MerkleNode
+	Block     uint64
+	Timestamp time.Time
+}
+
+type rootRecordJSON struct {
+	Root      string    `json:"root"`
+	Block     uint64    `json:"block"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (r RootRecord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rootRecordJSON{
+		Root:      hex.EncodeToString(r.Root[:]),
+		Block:     r.Block,
+		Timestamp: r.Timestamp,
+	})
+}
+
+func (r *RootRecord) UnmarshalJSON(b []byte) error {
+	var record rootRecordJSON
+	if err := json.Unmarshal(b, &record); err != nil {
+		return err
+	}
+
+	root, err := hex.DecodeString(record.Root)
+	if err != nil {
+		return err
+	}
+	if len(root) != len(r.Root) {
+		return errors.New("invalid root length")
+	}
+
+	copy(r.Root[:], root)
+	r.Block = record.Block
+	r.Timestamp = record.Timestamp
+	return nil
+}
+
+// rootHistory keeps the most recent roots of the tree, sorted by block
+type rootHistory struct {
+	mu      sync.RWMutex
+	size    int
+	path    string
+	records []RootRecord
+	// block tags the roots recorded by the next modifications of the tree
+	block uint64
+	// dirty indicates that the records changed since they were saved
+	dirty bool
+	// paused stops recording roots while a rollback restores the leaves one by one
+	paused bool
+}
+
+// rootHistoryPath returns the location of the file containing the root history
+// of a tree stored in `treePath`
+func rootHistoryPath(treePath string) string {
+	return filepath.Clean(treePath) + ".roots.json"
+}
+
+// EnableRootHistory starts recording the root of the tree after every modification, keeping the
+// last `size` roots. Roots are tagged with the block set with SetBlockNumber or AtomicOperationAtBlock.
+// If the tree is stored on disk, the history is saved next to it by Flush and Close, and the
+// roots recorded before a restart are loaded. A history file that can not be parsed, like one
+// truncated by a crash, is reported as a failed OpLoadRootHistory and replaced by an empty history
+func (r *RLN) EnableRootHistory(size int) error {
+	if size <= 0 {
+		return errors.New("root history size must be greater than 0")
+	}
+
+	history := &rootHistory{size: size}
+
+	if r.treePath != "" {
+		history.path = rootHistoryPath(r.treePath)
+
+		b, err := os.ReadFile(history.path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if err == nil {
+			r.loadRootHistory(history, b)
+		}
+		history.truncate()
+		if n := len(history.records); n != 0 {
+			history.block = history.records[n-1].Block
+		}
+	}
+
+	r.rootHistory = history
+	return nil
+}
+
+// loadRootHistory parses the saved records. The history only speeds up the acceptance of proofs
+// after a restart, so a corrupted file is discarded instead of preventing the use of the tree
+func (r *RLN) loadRootHistory(h *rootHistory, b []byte) {
+	var err error
+	defer r.track(OpLoadRootHistory, "path", h.path)(&err)
+
+	if err = json.Unmarshal(b, &h.records); err != nil {
+		err = fmt.Errorf("discarding the root history: %w", err)
+		h.records = nil
+		h.dirty = true
+	}
+}
+
+// SetBlockNumber sets the number of the block whose events are being applied to the tree.
+// The roots recorded in the history by the next modifications are tagged with it
+func (r *RLN) SetBlockNumber(block uint64) {
+	if r.rootHistory == nil {
+		return
+	}
+
+	r.rootHistory.mu.Lock()
+	defer r.rootHistory.mu.Unlock()
+	r.rootHistory.block = block
+}
+
+// RootsSince returns the recorded roots of blocks greater or equal than `block`, oldest first
+func (r *RLN) RootsSince(block uint64) []RootRecord {
+	if r.rootHistory == nil {
+		return nil
+	}
+
+	r.rootHistory.mu.RLock()
+	defer r.rootHistory.mu.RUnlock()
+
+	var result []RootRecord
+	for _, record := range r.rootHistory.records {
+		if record.Block >= block {
+			result = append(result, record)
+		}
+	}
+	return result
+}
+
+// IsRecentRoot indicates whether the root is part of the root history
+func (r *RLN) IsRecentRoot(root MerkleNode) bool {
+	if r.rootHistory == nil {
+		return false
+	}
+
+	r.rootHistory.mu.RLock()
+	defer r.rootHistory.mu.RUnlock()
+
+	for _, record := range r.rootHistory.records {
+		if record.Root == root {
+			return true
+		}
+	}
+	return false
+}
+
+// recordRoot adds the current root of the tree to the history. It is called once after every
+// modification of the tree, which already happened, so failures are not returned to the caller:
+// they are reported as OpGetMerkleRoot. The history is only written to disk by Flush, so
+// modifications do not wait for the file to be written
+func (r *RLN) recordRoot() {
+	if r.rootHistory == nil {
+		return
+	}
+
+	r.rootHistory.mu.RLock()
+	paused := r.rootHistory.paused
+	r.rootHistory.mu.RUnlock()
+	if paused {
+		return
+	}
+
+	// The error is reported by track
+	root, err := r.GetMerkleRoot()
+	if err != nil {
+		return
+	}
+
+	r.updateRootHistory(func(h *rootHistory) {
+		h.records = append(h.records, RootRecord{
+			Root:      root,
+			Block:     h.block,
+			Timestamp: time.Now().UTC(),
+		})
+		h.truncate()
+	})
+}
+
+// pauseRootHistory stops or resumes recording roots
+func (r *RLN) pauseRootHistory(paused bool) {
+	if r.rootHistory == nil {
+		return
+	}
+
+	r.rootHistory.mu.Lock()
+	defer r.rootHistory.mu.Unlock()
+	r.rootHistory.paused = paused
+}
+
+// forgetRootsAfter removes the roots of blocks greater than `block`, which becomes the current block
+func (r *RLN) forgetRootsAfter(block uint64) {
+	if r.rootHistory == nil {
+		return
+	}
+
+	r.updateRootHistory(func(h *rootHistory) {
+		i := len(h.records)
+		for i > 0 && h.records[i-1].Block > block {
+			i--
+		}
+		h.records = h.records[:i]
+		h.block = block
+	})
+}
+
+// updateRootHistory applies the update to the history, which is saved by the next Flush
+func (r *RLN) updateRootHistory(update func(h *rootHistory)) {
+	h := r.rootHistory
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	update(h)
+	h.dirty = true
+}
+
+// saveRootHistory saves the history if it changed and it is stored on disk
+func (r *RLN) saveRootHistory() (err error) {
+	h := r.rootHistory
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.dirty {
+		return nil
+	}
+
+	defer r.track(OpSaveRootHistory, "records", len(h.records))(&err)
+
+	if err := h.save(); err != nil {
+		return err
+	}
+	h.dirty = false
+	return nil
+}
+
+func (h *rootHistory) truncate() {
+	if len(h.records) > h.size {
+		h.records = append([]RootRecord(nil), h.records[len(h.records)-h.size:]...)
+	}
+}
+
+// save writes the history to a temporary file which then replaces the previous one,
+// so a crash does not leave a partially written history
+func (h *rootHistory) save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(h.records)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := h.path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	// The content must be on disk before the rename, or a crash could leave an empty file
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, h.path)
+}
@@ -0,0 +1,202 @@
+package rln
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func (s *RLNSuite) TestRootHistory() {
+	treePath := filepath.Join(s.T().TempDir(), "tree")
+	rln, err := NewWithConfig(DefaultTreeDepth, &TreeConfig{
+		CacheCapacity: 15000,
+		Mode:          HighThroughput,
+		FlushInterval: 500 * time.Millisecond,
+		Path:          treePath,
+	})
+	s.NoError(err)
+
+	// Disabled
+	s.Nil(rln.RootsSince(0))
+
+	err = rln.EnableRootHistory(0)
+	s.Error(err)
+
+	err = rln.EnableRootHistory(3)
+	s.NoError(err)
+	rln.EnableJournal()
+
+	var roots []MerkleNode
+	for block := uint64(1); block <= 4; block++ {
+		err = rln.AtomicOperationAtBlock(block, MembershipIndex(block-1), []IDCommitment{{byte(block)}}, nil)
+		s.NoError(err)
+
+		root, err := rln.GetMerkleRoot()
+		s.NoError(err)
+		roots = append(roots, root)
+	}
+
+	// Only the last 3 roots are kept
+	s.False(rln.IsRecentRoot(roots[0]))
+	s.True(rln.IsRecentRoot(roots[1]))
+	s.True(rln.IsRecentRoot(roots[3]))
+
+	records := rln.RootsSince(3)
+	s.Len(records, 2)
+	s.Equal(uint64(3), records[0].Block)
+	s.Equal(roots[2], records[0].Root)
+	s.Equal(uint64(4), records[1].Block)
+	s.Equal(roots[3], records[1].Root)
+	s.False(records[1].Timestamp.IsZero())
+
+	// Blocks must be increasing
+	err = rln.AtomicOperationAtBlock(2, 10, []IDCommitment{{0xff}}, nil)
+	s.Error(err)
+
+	// Roots of orphaned blocks are removed
+	err = rln.RollbackTo(3)
+	s.NoError(err)
+	s.False(rln.IsRecentRoot(roots[3]))
+	s.True(rln.IsRecentRoot(roots[2]))
+
+	// The history is written to disk by Flush, not by every modification
+	_, err = os.Stat(rootHistoryPath(treePath))
+	s.ErrorIs(err, os.ErrNotExist)
+	err = rln.Flush()
+	s.NoError(err)
+
+	// The history is loaded by a tree using the same path. The sidecar file is copied
+	// since the tree database can not be opened twice by the same process
+	b, err := os.ReadFile(rootHistoryPath(treePath))
+	s.NoError(err)
+
+	treePath2 := filepath.Join(s.T().TempDir(), "tree")
+	err = os.WriteFile(rootHistoryPath(treePath2), b, 0600)
+	s.NoError(err)
+
+	rln2, err := NewWithConfig(DefaultTreeDepth, &TreeConfig{
+		CacheCapacity: 15000,
+		Mode:          HighThroughput,
+		FlushInterval: 500 * time.Millisecond,
+		Path:          treePath2,
+	})
+	s.NoError(err)
+
+	err = rln2.EnableRootHistory(2)
+	s.NoError(err)
+	s.Equal(rln.RootsSince(2), rln2.RootsSince(0))
+	s.True(rln2.IsRecentRoot(roots[2]))
+	s.False(rln2.IsRecentRoot(roots[3]))
+}
+
+func (s *RLNSuite) TestRootHistoryAllModifications() {
+	treePath := filepath.Join(s.T().TempDir(), "tree")
+	rln, err := NewWithConfig(DefaultTreeDepth, &TreeConfig{
+		CacheCapacity: 15000,
+		Mode:          HighThroughput,
+		FlushInterval: 500 * time.Millisecond,
+		Path:          treePath,
+	})
+	s.NoError(err)
+
+	err = rln.EnableRootHistory(10)
+	s.NoError(err)
+
+	var roots []MerkleNode
+	recordRoot := func() {
+		root, err := rln.GetMerkleRoot()
+		s.NoError(err)
+		roots = append(roots, root)
+	}
+
+	rln.SetBlockNumber(5)
+	err = rln.InsertMember(IDCommitment{1})
+	s.NoError(err)
+	recordRoot()
+	err = rln.InsertMembers(1, []IDCommitment{{2}, {3}})
+	s.NoError(err)
+	recordRoot()
+
+	rln.SetBlockNumber(6)
+	err = rln.DeleteMember(0)
+	s.NoError(err)
+	recordRoot()
+	err = rln.AtomicOperation(3, []IDCommitment{{4}}, nil)
+	s.NoError(err)
+	recordRoot()
+
+	records := rln.RootsSince(0)
+	s.Len(records, 4)
+	for i, record := range records {
+		s.Equal(roots[i], record.Root)
+	}
+	s.Equal(uint64(5), records[1].Block)
+	s.Equal(uint64(6), records[2].Block)
+	s.Len(rln.RootsSince(6), 2)
+
+	// A failure saving the history does not fail the modification, which already happened
+	tmpPath := rootHistoryPath(treePath) + ".tmp"
+	err = os.Mkdir(tmpPath, 0700)
+	s.NoError(err)
+
+	err = rln.InsertMember(IDCommitment{5})
+	s.NoError(err)
+	recordRoot()
+	s.True(rln.IsRecentRoot(roots[4]))
+
+	err = rln.Flush()
+	s.Error(err)
+
+	err = os.Remove(tmpPath)
+	s.NoError(err)
+	err = rln.Flush()
+	s.NoError(err)
+
+	var saved []RootRecord
+	b, err := os.ReadFile(rootHistoryPath(treePath))
+	s.NoError(err)
+	err = json.Unmarshal(b, &saved)
+	s.NoError(err)
+	s.Len(saved, 5)
+	s.Equal(roots[4], saved[4].Root)
+}
+
+func (s *RLNSuite) TestRootHistoryCorruptedFile() {
+	treePath := filepath.Join(s.T().TempDir(), "tree")
+
+	// Truncated by a crash
+	err := os.WriteFile(rootHistoryPath(treePath), []byte(`[{"root":"00`), 0600)
+	s.NoError(err)
+
+	rln, err := NewWithConfig(DefaultTreeDepth, &TreeConfig{
+		CacheCapacity: 15000,
+		Mode:          HighThroughput,
+		FlushInterval: 500 * time.Millisecond,
+		Path:          treePath,
+	})
+	s.NoError(err)
+
+	metrics := &recordingMetrics{}
+	rln.SetMetrics(metrics)
+
+	err = rln.EnableRootHistory(10)
+	s.NoError(err)
+	s.Empty(rln.RootsSince(0))
+	s.Len(metrics.operations, 1)
+	s.Equal(OpLoadRootHistory, metrics.operations[0].op)
+	s.True(metrics.operations[0].failed)
+
+	// The corrupted file is replaced by the next Flush
+	err = rln.InsertMember(IDCommitment{1})
+	s.NoError(err)
+	err = rln.Flush()
+	s.NoError(err)
+
+	var saved []RootRecord
+	b, err := os.ReadFile(rootHistoryPath(treePath))
+	s.NoError(err)
+	err = json.Unmarshal(b, &saved)
+	s.NoError(err)
+	s.Equal(rln.RootsSince(0), saved)
+}
@@ -25,27 +25,38 @@ func serialize(idKey IDSecretHash, memIndex MembershipIndex, epoch Epoch, msg []
 	return output
 }
 
+// rateLimitProofSize is the size of a serialized RateLimitProof
+const rateLimitProofSize = 128 + 6*32
+
 // serialize converts a RateLimitProof and data to a byte seq
 // this conversion is used in the proof verification proc
 // the order of serialization is based on https://github.com/kilic/rln/blob/7ac74183f8b69b399e3bc96c1ae8ab61c026dc43/src/public.rs#L205
 // [ proof<128> | root<32> | epoch<32> | share_x<32> | share_y<32> | nullifier<32> | rln_identifier<32> | signal_len<8> | signal<var> ]
 func (r RateLimitProof) serializeWithData(data []byte) []byte {
-	lenPrefMsg := appendLength(data)
-	proofBytes := r.serialize()
-	proofBytes = append(proofBytes, lenPrefMsg...)
-	return proofBytes
+	return r.appendWithData(make([]byte, 0, rateLimitProofSize+8+len(data)), data)
+}
+
+// appendWithData appends the serialization done by serializeWithData to dst, so a buffer can be reused
+func (r *RateLimitProof) appendWithData(dst []byte, data []byte) []byte {
+	dst = r.appendTo(dst)
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(len(data)))
+	return append(dst, data...)
 }
 
 // serialize converts a RateLimitProof to a byte seq
 // [ proof<128> | root<32> | epoch<32> | share_x<32> | share_y<32> | nullifier<32> | rln_identifier<32>
 func (r RateLimitProof) serialize() []byte {
-	proofBytes := append(r.Proof[:], r.MerkleRoot[:]...)
-	proofBytes = append(proofBytes, r.Epoch[:]...)
-	proofBytes = append(proofBytes, r.ShareX[:]...)
-	proofBytes = append(proofBytes, r.ShareY[:]...)
-	proofBytes = append(proofBytes, r.Nullifier[:]...)
-	proofBytes = append(proofBytes, r.RLNIdentifier[:]...)
-	return proofBytes
+	return r.appendTo(make([]byte, 0, rateLimitProofSize))
+}
+
+func (r *RateLimitProof) appendTo(dst []byte) []byte {
+	dst = append(dst, r.Proof[:]...)
+	dst = append(dst, r.MerkleRoot[:]...)
+	dst = append(dst, r.Epoch[:]...)
+	dst = append(dst, r.ShareX[:]...)
+	dst = append(dst, r.ShareY[:]...)
+	dst = append(dst, r.Nullifier[:]...)
+	return append(dst, r.RLNIdentifier[:]...)
 }
 
 func (r *RLNWitnessInput) serialize() []byte {
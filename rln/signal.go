@@ -0,0 +1,106 @@
+package rln
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// SignalHasher maps a signal (the message protected by RLN) to the field element
+// used as `x` in the RLN circuit. The result must be a field element serialized
+// as 32 bytes in little endian
+type SignalHasher interface {
+	HashSignal(data []byte) ([32]byte, error)
+}
+
+// KeccakSignalHasher hashes the signal with Keccak256 and reduces the result to a field element.
+// This is the hash used internally by zerokit, and the SignalHasher used by default
+type KeccakSignalHasher struct{}
+
+func (KeccakSignalHasher) HashSignal(data []byte) ([32]byte, error) {
+	return HashToBN255(data), nil
+}
+
+// poseidonChunkSize is the number of bytes of the signal absorbed on each Poseidon
+// invocation. 31 bytes always fit in a field element, so no reduction is needed
+const poseidonChunkSize = 31
+
+// PoseidonSignalHasher hashes the signal with Poseidon. The signal is split in chunks of 31 bytes,
+// each one interpreted as a little endian field element, and absorbed one at a time starting
+// from the signal length: h = Poseidon(len), h = Poseidon(h, chunk_i)
+type PoseidonSignalHasher struct{}
+
+func (PoseidonSignalHasher) HashSignal(data []byte) ([32]byte, error) {
+	var length [32]byte
+	binary.LittleEndian.PutUint64(length[:], uint64(len(data)))
+
+	result, err := poseidonHash(length)
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	for offset := 0; offset < len(data); offset += poseidonChunkSize {
+		end := offset + poseidonChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		var chunk [32]byte
+		copy(chunk[:], data[offset:end])
+
+		result, err = poseidonHash(result, chunk)
+		if err != nil {
+			return [32]byte{}, err
+		}
+	}
+
+	return result, nil
+}
+
+// SetSignalHasher configures the SignalHasher used by GenerateProof and Verify.
+// Using nil restores the KeccakSignalHasher. The VerifyCache of the instance is purged,
+// since the outcomes it contains were obtained hashing the signals with the previous hasher
+func (r *RLN) SetSignalHasher(hasher SignalHasher) {
+	r.signalHasher = hasher
+	if r.verifyCache != nil {
+		r.verifyCache.Purge()
+	}
+}
+
+func (r *RLN) getSignalHasher() SignalHasher {
+	if r.signalHasher == nil {
+		return KeccakSignalHasher{}
+	}
+	return r.signalHasher
+}
+
+// usesZerokitHasher indicates whether the signal can be sent as is to zerokit,
+// which hashes it internally with Keccak256
+func (r *RLN) usesZerokitHasher() bool {
+	_, ok := r.getSignalHasher().(KeccakSignalHasher)
+	return ok
+}
+
+// verifyWithSignalHasher verifies a proof whose signal was hashed with a SignalHasher zerokit
+// does not know about, or any proof in a verifier-only instance. The signal and root checks
+// done by zerokit's verify_with_roots are replicated here, and the zkSNARK is verified on the Go side
+func (r *RLN) verifyWithSignalHasher(data []byte, proof RateLimitProof, roots []MerkleNode) (bool, error) {
+	x, err := r.getSignalHasher().HashSignal(data)
+	if err != nil {
+		return false, fmt.Errorf("could not hash the signal: %w", err)
+	}
+
+	if x != proof.ShareX {
+		return false, nil
+	}
+
+	if len(roots) != 0 && !containsRoot(roots, proof.MerkleRoot) {
+		return false, nil
+	}
+
+	vk, err := r.verifyingKey()
+	if err != nil {
+		return false, err
+	}
+
+	return vk.verify(proof)
+}
@@ -0,0 +1,187 @@
+package rln
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoseidonSignalHasher(t *testing.T) {
+	hasher := PoseidonSignalHasher{}
+
+	h1, err := hasher.HashSignal([]byte("some rln protected message"))
+	require.NoError(t, err)
+
+	h2, err := hasher.HashSignal([]byte("some rln protected message"))
+	require.NoError(t, err)
+	require.Equal(t, h1, h2)
+
+	// Trailing zeros must not collide with a shorter signal
+	h3, err := hasher.HashSignal([]byte("some rln protected message\x00"))
+	require.NoError(t, err)
+	require.NotEqual(t, h1, h3)
+
+	empty, err := hasher.HashSignal(nil)
+	require.NoError(t, err)
+	require.NotEqual(t, [32]byte{}, empty)
+}
+
+func TestBuiltinVerifyingKeys(t *testing.T) {
+	for _, treeDepth := range []TreeDepth{TreeDepth15, TreeDepth19, TreeDepth20} {
+		b, err := builtinVerifyingKey(treeDepth)
+		require.NoError(t, err)
+
+		_, err = parseVerifyingKey(b)
+		require.NoError(t, err)
+	}
+
+	_, err := builtinVerifyingKey(TreeDepth(32))
+	require.Error(t, err)
+}
+
+func (s *RLNSuite) TestGoSideVerificationMatchesZerokit() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	memKeys, err := rln.MembershipKeyGen()
+	s.NoError(err)
+
+	err = rln.InsertMember(memKeys.IDCommitment)
+	s.NoError(err)
+
+	msg := []byte("Hello")
+	proofRes, err := rln.GenerateProof(msg, *memKeys, MembershipIndex(0), ToEpoch(1000))
+	s.NoError(err)
+
+	vk, err := rln.verifyingKey()
+	s.NoError(err)
+
+	verified, err := vk.verify(*proofRes)
+	s.NoError(err)
+	s.True(verified)
+
+	proofRes.ShareX = HashToBN255([]byte("different message"))
+	verified, err = vk.verify(*proofRes)
+	s.NoError(err)
+	s.False(verified)
+}
+
+func (s *RLNSuite) TestCustomSignalHasher() {
+	rln, err := NewRLN()
+	s.NoError(err)
+	rln.SetSignalHasher(PoseidonSignalHasher{})
+
+	memKeys, err := rln.MembershipKeyGen()
+	s.NoError(err)
+
+	err = rln.InsertMember(memKeys.IDCommitment)
+	s.NoError(err)
+
+	root, err := rln.GetMerkleRoot()
+	s.NoError(err)
+
+	msg := []byte("Hello")
+	epoch := ToEpoch(1000)
+
+	proofRes, err := rln.GenerateProof(msg, *memKeys, MembershipIndex(0), epoch)
+	s.NoError(err)
+
+	x, err := PoseidonSignalHasher{}.HashSignal(msg)
+	s.NoError(err)
+	s.Equal(x, proofRes.ShareX)
+
+	verified, err := rln.Verify(msg, *proofRes, root)
+	s.NoError(err)
+	s.True(verified)
+
+	verified, err = rln.Verify([]byte("different message"), *proofRes, root)
+	s.NoError(err)
+	s.False(verified)
+
+	verified, err = rln.Verify(msg, *proofRes, MerkleNode{0x01})
+	s.NoError(err)
+	s.False(verified)
+
+	// A witness built with the same hasher produces an equivalent proof
+	merkleProof, err := rln.GetMerkleProof(0)
+	s.NoError(err)
+
+	witness, err := CreateWitnessWithHasher(PoseidonSignalHasher{}, memKeys.IDSecretHash, msg, epoch, merkleProof)
+	s.NoError(err)
+
+	proofRes2, err := rln.GenerateRLNProofWithWitness(witness)
+	s.NoError(err)
+	s.Equal(proofRes.ShareY, proofRes2.ShareY)
+	s.Equal(proofRes.Nullifier, proofRes2.Nullifier)
+
+	// Keccak based proofs are not accepted by an instance configured with another hasher
+	rln.SetSignalHasher(nil)
+	keccakProof, err := rln.GenerateProof(msg, *memKeys, MembershipIndex(0), epoch)
+	s.NoError(err)
+
+	rln.SetSignalHasher(PoseidonSignalHasher{})
+	verified, err = rln.Verify(msg, *keccakProof, root)
+	s.NoError(err)
+	s.False(verified)
+}
+
+func (s *RLNSuite) TestGoSideVerificationRejectsMalformedProofs() {
+	rln, proof, root := verifyFixture(s.T())
+
+	verifKey, err := builtinVerifyingKey(DefaultTreeDepth)
+	s.NoError(err)
+	verifier, err := NewVerifier(verifKey)
+	s.NoError(err)
+
+	vk, err := verifier.verifyingKey()
+	s.NoError(err)
+
+	valid, err := vk.verify(*proof)
+	s.NoError(err)
+	s.True(valid)
+
+	// nonCanonical replaces the x coordinate of a compressed point, keeping its flags, with a
+	// value greater than the modulus of the base field
+	nonCanonical := func(point []byte) {
+		flags := point[len(point)-1] & arkworksFlagsMask
+		for i := range point {
+			point[i] = 0xff
+		}
+		point[len(point)-1] = 0x3f | flags
+	}
+
+	for name, malform := range map[string]func(p *RateLimitProof){
+		"changed A":         func(p *RateLimitProof) { p.Proof[0] ^= 0x01 },
+		"changed B":         func(p *RateLimitProof) { p.Proof[32] ^= 0x01 },
+		"changed C":         func(p *RateLimitProof) { p.Proof[96] ^= 0x01 },
+		"A at infinity":     func(p *RateLimitProof) { p.Proof[31] = p.Proof[31]&^arkworksFlagsMask | arkworksPointAtInfinity },
+		"C at infinity":     func(p *RateLimitProof) { p.Proof[127] = p.Proof[127]&^arkworksFlagsMask | arkworksPointAtInfinity },
+		"A with both flags": func(p *RateLimitProof) { p.Proof[31] |= arkworksFlagsMask },
+		"B with both flags": func(p *RateLimitProof) { p.Proof[95] |= arkworksFlagsMask },
+		"C with both flags": func(p *RateLimitProof) { p.Proof[127] |= arkworksFlagsMask },
+		"A sign flipped":    func(p *RateLimitProof) { p.Proof[31] ^= arkworksYIsNegative },
+		"non-canonical A":   func(p *RateLimitProof) { nonCanonical(p.Proof[0:32]) },
+		"non-canonical B":   func(p *RateLimitProof) { nonCanonical(p.Proof[64:96]) },
+		"non-canonical C":   func(p *RateLimitProof) { nonCanonical(p.Proof[96:128]) },
+		"wrong epoch":       func(p *RateLimitProof) { p.Epoch = ToEpoch(1001) },
+		"wrong identifier":  func(p *RateLimitProof) { p.RLNIdentifier[0] ^= 0x01 },
+		"wrong nullifier":   func(p *RateLimitProof) { p.Nullifier[0] ^= 0x01 },
+		"wrong share y":     func(p *RateLimitProof) { p.ShareY[0] ^= 0x01 },
+		"zero proof":        func(p *RateLimitProof) { p.Proof = ZKSNARK{} },
+	} {
+		malformed := *proof
+		malform(&malformed)
+
+		zerokitValid, zerokitErr := rln.Verify(benchmarkSignal, malformed, root)
+		s.False(zerokitValid, name)
+
+		// Points zerokit cannot deserialize are reported as errors on both sides
+		goValid, goErr := vk.verify(malformed)
+		s.False(goValid, name)
+		s.Equal(zerokitErr != nil, goErr != nil, name)
+
+		verifierValid, verifierErr := verifier.Verify(benchmarkSignal, malformed, root)
+		s.False(verifierValid, name)
+		s.Equal(zerokitErr != nil, verifierErr != nil, name)
+	}
+}
@@ -0,0 +1,95 @@
+package rln
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SimulateAtomicOperation returns the root the tree would have after calling AtomicOperation
+// with the same arguments, without modifying the tree. The root is calculated on the Go side with
+// the Merkle proofs of the modified leaves, so it requires one proof per inserted or removed member.
+// Only the operations zerokit applies as requested are supported: at most one index can be
+// removed, and when members are inserted too, the removed index can not be greater than `index`.
+// If it is equal, the inserted member replaces the removed one. An error is returned otherwise
+func (r *RLN) SimulateAtomicOperation(index MembershipIndex, idCommsToInsert []IDCommitment, indicesToRemove []MembershipIndex) (MerkleNode, error) {
+	if uint64(index)+uint64(len(idCommsToInsert)) > r.capacity() {
+		return MerkleNode{}, ErrTreeFull
+	}
+
+	// zerokit clears other leaves than the requested ones when removing several indices, and aborts
+	// the process when removing an index after the first inserted one
+	if len(indicesToRemove) > 1 {
+		return MerkleNode{}, errors.New("atomic operations removing several indices are not supported")
+	}
+
+	leaves := make(map[uint64]MerkleNode, len(idCommsToInsert)+len(indicesToRemove))
+	for _, i := range indicesToRemove {
+		if uint64(i) >= r.capacity() {
+			return MerkleNode{}, fmt.Errorf("index %d exceeds the tree capacity %d", i, r.capacity())
+		}
+		if len(idCommsToInsert) != 0 && i > index {
+			return MerkleNode{}, fmt.Errorf("cannot remove index %d after the inserted members, which start at index %d", i, index)
+		}
+		// Like AtomicOperation, which fails to remove a leaf that was never set
+		if len(idCommsToInsert) == 0 && uint(i) >= r.LeavesSet() {
+			return MerkleNode{}, fmt.Errorf("index %d was never set", i)
+		}
+		leaves[uint64(i)] = MerkleNode{}
+	}
+	for i, idComm := range idCommsToInsert {
+		leaves[uint64(index)+uint64(i)] = MerkleNode(idComm)
+	}
+
+	if len(leaves) == 0 {
+		return r.GetMerkleRoot()
+	}
+
+	// siblings[level] contains the current value of the nodes next to the path of each modified leaf
+	siblings := make([]map[uint64]MerkleNode, r.depth)
+	for level := range siblings {
+		siblings[level] = make(map[uint64]MerkleNode)
+	}
+
+	for i := range leaves {
+		proof, err := r.GetMerkleProof(MembershipIndex(i))
+		if err != nil {
+			return MerkleNode{}, err
+		}
+		if err := proof.ValidateDepth(r.depth); err != nil {
+			return MerkleNode{}, err
+		}
+		for level, element := range proof.PathElements {
+			siblings[level][(i>>level)^1] = element
+		}
+	}
+
+	// Hash the modified nodes level by level, using the new value of a sibling if it was modified too
+	nodes := leaves
+	for level := 0; level < int(r.depth); level++ {
+		parents := make(map[uint64]MerkleNode, (len(nodes)+1)/2)
+		for i, node := range nodes {
+			parent := i >> 1
+			if _, ok := parents[parent]; ok {
+				continue
+			}
+
+			sibling, ok := nodes[i^1]
+			if !ok {
+				sibling = siblings[level][i^1]
+			}
+
+			var err error
+			if i&1 == 0 {
+				parents[parent], err = poseidonHash(node, sibling)
+			} else {
+				parents[parent], err = poseidonHash(sibling, node)
+			}
+			if err != nil {
+				return MerkleNode{}, err
+			}
+		}
+		nodes = parents
+	}
+
+	return nodes[0], nil
+}
@@ -0,0 +1,87 @@
+package rln
+
+func (s *RLNSuite) TestSimulateAtomicOperation() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	var commitments []IDCommitment
+	for i := 0; i < 8; i++ {
+		keypair, err := rln.MembershipKeyGen()
+		s.NoError(err)
+		commitments = append(commitments, keypair.IDCommitment)
+	}
+
+	// No changes
+	root, err := rln.GetMerkleRoot()
+	s.NoError(err)
+	simulatedRoot, err := rln.SimulateAtomicOperation(0, nil, nil)
+	s.NoError(err)
+	s.Equal(root, simulatedRoot)
+
+	type operation struct {
+		index   MembershipIndex
+		inserts []IDCommitment
+		removes []MembershipIndex
+	}
+
+	operations := []operation{
+		{index: 0, inserts: commitments[:3]},
+		{index: 3, inserts: commitments[3:5], removes: []MembershipIndex{1}},
+		{index: 0, removes: []MembershipIndex{4}},
+		// Sibling leaves modified in the same operation
+		{index: 6, inserts: commitments[5:8], removes: []MembershipIndex{2}},
+		// The inserted member replaces the removed one
+		{index: 8, inserts: commitments[:2], removes: []MembershipIndex{8}},
+		// Removal only, with a start index greater than the removed index
+		{index: 9, removes: []MembershipIndex{3}},
+		// Far from the other leaves
+		{index: 1 << 19, inserts: commitments[:1]},
+	}
+
+	for _, op := range operations {
+		rootBefore, err := rln.GetMerkleRoot()
+		s.NoError(err)
+
+		simulatedRoot, err := rln.SimulateAtomicOperation(op.index, op.inserts, op.removes)
+		s.NoError(err)
+
+		// The tree was not modified
+		root, err := rln.GetMerkleRoot()
+		s.NoError(err)
+		s.Equal(rootBefore, root)
+
+		err = rln.AtomicOperation(op.index, op.inserts, op.removes)
+		s.NoError(err)
+
+		root, err = rln.GetMerkleRoot()
+		s.NoError(err)
+		s.Equal(root, simulatedRoot)
+	}
+
+	_, err = rln.SimulateAtomicOperation(1<<20, commitments[:1], nil)
+	s.ErrorIs(err, ErrTreeFull)
+
+	_, err = rln.SimulateAtomicOperation(0, nil, []MembershipIndex{1 << 20})
+	s.Error(err)
+
+	// Operations that zerokit does not apply as requested, or that abort the process, are rejected
+	rootBefore, err := rln.GetMerkleRoot()
+	s.NoError(err)
+	for _, op := range []operation{
+		// Several removals
+		{index: 0, removes: []MembershipIndex{2, 4}},
+		{index: 20, inserts: commitments[:1], removes: []MembershipIndex{2, 4}},
+		// Removal inside the inserted range
+		{index: 4, inserts: commitments[:3], removes: []MembershipIndex{5}},
+		// Removal after the inserted range
+		{index: 4, inserts: commitments[:3], removes: []MembershipIndex{7}},
+		// Removal of a leaf that was never set
+		{index: 0, removes: []MembershipIndex{1<<19 + 5}},
+	} {
+		_, err := rln.SimulateAtomicOperation(op.index, op.inserts, op.removes)
+		s.Error(err, "index %d, inserts %d, removes %v", op.index, len(op.inserts), op.removes)
+	}
+	root, err = rln.GetMerkleRoot()
+	s.NoError(err)
+	s.Equal(rootBefore, root)
+}
@@ -0,0 +1,185 @@
+package rln
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var snapshotMagic = [8]byte{'R', 'L', 'N', 'S', 'N', 'A', 'P', 0}
+
+// Version of the snapshot format produced by ExportSnapshot
+const snapshotVersion = uint64(1)
+
+// ExportSnapshot writes all the leaves of the tree up to the next index, the metadata and
+// the root, so the tree can be rebuilt with ImportSnapshot much faster than inserting each
+// member. The snapshot is serialized as
+// [ magic<8> | version<8> | depth<8> | next_index<8> | metadata_len<8> | metadata<var> | root<32> | leaves<32*next_index> | checksum<32> ]
+// where checksum is the SHA-256 of all the preceding bytes. Integers are little endian.
+// The tree cannot be modified while it is exported, so the root matches the leaves
+func (r *RLN) ExportSnapshot(w io.Writer) error {
+	if err := r.lockWrites(); err != nil {
+		return err
+	}
+	defer r.writeMu.Unlock()
+
+	nextIndex := r.LeavesSet()
+
+	// zerokit fails when no metadata has been stored yet
+	metadata, err := r.GetMetadata()
+	if err != nil {
+		metadata = nil
+	}
+
+	root, err := r.GetMerkleRoot()
+	if err != nil {
+		return err
+	}
+
+	hasher := sha256.New()
+	bw := bufio.NewWriter(io.MultiWriter(w, hasher))
+
+	header := make([]byte, 0, 8*5)
+	header = append(header, snapshotMagic[:]...)
+	header = binary.LittleEndian.AppendUint64(header, snapshotVersion)
+	header = binary.LittleEndian.AppendUint64(header, uint64(r.depth))
+	header = binary.LittleEndian.AppendUint64(header, uint64(nextIndex))
+	header = binary.LittleEndian.AppendUint64(header, uint64(len(metadata)))
+
+	if _, err := bw.Write(header); err != nil {
+		return err
+	}
+	if _, err := bw.Write(metadata); err != nil {
+		return err
+	}
+	if _, err := bw.Write(root[:]); err != nil {
+		return err
+	}
+
+	for i := MembershipIndex(0); i < nextIndex; i++ {
+		leaf, err := r.GetLeaf(i)
+		if err != nil {
+			return fmt.Errorf("could not read leaf %d: %w", i, err)
+		}
+		if _, err := bw.Write(leaf[:]); err != nil {
+			return err
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	_, err = w.Write(hasher.Sum(nil))
+	return err
+}
+
+// ErrTreeNotEmpty is returned by ImportSnapshot when the tree already contains leaves
+var ErrTreeNotEmpty = errors.New("tree is not empty")
+
+// ImportSnapshot rebuilds the tree from a snapshot created with ExportSnapshot. The snapshot
+// must have been created for a tree of the same depth, and can only be imported into an empty
+// tree: resetting a tree in zerokit replaces persistent trees by an in-memory one. The root of the
+// snapshot is checked against its leaves before modifying the tree, and the leaves are then
+// loaded with a single batch insertion
+func (r *RLN) ImportSnapshot(reader io.Reader) error {
+	if err := r.loadTree(); err != nil {
+		return err
+	}
+
+	if r.LeavesSet() != 0 {
+		return ErrTreeNotEmpty
+	}
+
+	b, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	if len(b) < sha256.Size {
+		return errors.New("invalid snapshot: too short")
+	}
+
+	content := b[:len(b)-sha256.Size]
+	checksum := sha256.Sum256(content)
+	if !bytes.Equal(checksum[:], b[len(b)-sha256.Size:]) {
+		return errors.New("invalid snapshot: checksum mismatch")
+	}
+
+	if len(content) < 8*5 || !bytes.Equal(content[:8], snapshotMagic[:]) {
+		return errors.New("invalid snapshot: unknown format")
+	}
+
+	offset := 8
+	readUint64 := func() uint64 {
+		v := binary.LittleEndian.Uint64(content[offset : offset+8])
+		offset += 8
+		return v
+	}
+
+	if version := readUint64(); version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version: %d", version)
+	}
+
+	if depth := readUint64(); depth != uint64(r.depth) {
+		return fmt.Errorf("snapshot was created for a tree of depth %d, but the tree depth is %d", depth, r.depth)
+	}
+
+	nextIndex := readUint64()
+	metadataLen := readUint64()
+
+	expectedLen := uint64(offset) + metadataLen + 32 + 32*nextIndex
+	if metadataLen > uint64(len(content)) || nextIndex > uint64(len(content)) || uint64(len(content)) != expectedLen {
+		return fmt.Errorf("invalid snapshot: expected %d bytes, got %d", expectedLen, len(content))
+	}
+
+	metadata := content[offset : offset+int(metadataLen)]
+	offset += int(metadataLen)
+
+	var root MerkleNode
+	copy(root[:], content[offset:offset+32])
+	offset += 32
+
+	leaves := make([]IDCommitment, nextIndex)
+	for i := range leaves {
+		copy(leaves[i][:], content[offset:offset+32])
+		offset += 32
+	}
+
+	if err := checkSnapshotRoot(r.depth, leaves, root); err != nil {
+		return err
+	}
+
+	if len(leaves) != 0 {
+		if err := r.setLeavesFrom(0, leaves); err != nil {
+			return err
+		}
+	}
+
+	if len(metadata) != 0 {
+		if err := r.SetMetadata(metadata); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkSnapshotRoot computes the root of a tree with the leaves and compares it with the one
+// in the snapshot
+func checkSnapshotRoot(depth TreeDepth, leaves []IDCommitment, root MerkleNode) error {
+	levels, err := computeTreeLevels(leaves, depth)
+	if err != nil {
+		return err
+	}
+
+	if levels.node(int(depth), 0) != root {
+		return errors.New("invalid snapshot: the root of the imported tree does not match the snapshot root")
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package rln
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+func (s *RLNSuite) TestSnapshotExportImport() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	var commitments []IDCommitment
+	for i := 0; i < 10; i++ {
+		keypair, err := rln.MembershipKeyGen()
+		s.NoError(err)
+		commitments = append(commitments, keypair.IDCommitment)
+	}
+
+	err = rln.InsertMembers(0, commitments)
+	s.NoError(err)
+
+	err = rln.DeleteMember(4)
+	s.NoError(err)
+
+	err = rln.SetMetadata([]byte("some metadata"))
+	s.NoError(err)
+
+	root, err := rln.GetMerkleRoot()
+	s.NoError(err)
+
+	var snapshot bytes.Buffer
+	err = rln.ExportSnapshot(&snapshot)
+	s.NoError(err)
+
+	// Import into an empty tree
+	rln2, err := NewRLN()
+	s.NoError(err)
+
+	err = rln2.ImportSnapshot(bytes.NewReader(snapshot.Bytes()))
+	s.NoError(err)
+
+	root2, err := rln2.GetMerkleRoot()
+	s.NoError(err)
+	s.Equal(root, root2)
+	s.Equal(rln.LeavesSet(), rln2.LeavesSet())
+
+	metadata, err := rln2.GetMetadata()
+	s.NoError(err)
+	s.Equal([]byte("some metadata"), metadata)
+
+	leaf, err := rln2.GetLeaf(9)
+	s.NoError(err)
+	s.Equal(commitments[9], leaf)
+
+	// Import into a tree that already contains other members
+	rln3, err := NewRLN()
+	s.NoError(err)
+
+	err = rln3.InsertMember(IDCommitment{0x01})
+	s.NoError(err)
+
+	root3, err := rln3.GetMerkleRoot()
+	s.NoError(err)
+
+	err = rln3.ImportSnapshot(bytes.NewReader(snapshot.Bytes()))
+	s.ErrorIs(err, ErrTreeNotEmpty)
+
+	root3After, err := rln3.GetMerkleRoot()
+	s.NoError(err)
+	s.Equal(root3, root3After)
+
+	// A snapshot with a wrong root does not modify the tree
+	wrongRoot := append([]byte(nil), snapshot.Bytes()...)
+	rootOffset := 8*5 + len("some metadata")
+	wrongRoot[rootOffset] ^= 0x01
+	checksum := sha256.Sum256(wrongRoot[:len(wrongRoot)-sha256.Size])
+	copy(wrongRoot[len(wrongRoot)-sha256.Size:], checksum[:])
+
+	rln5, err := NewRLN()
+	s.NoError(err)
+	err = rln5.ImportSnapshot(bytes.NewReader(wrongRoot))
+	s.ErrorContains(err, "root")
+	s.Equal(uint(0), rln5.LeavesSet())
+
+	// Corrupted snapshots are rejected
+	corrupted := append([]byte(nil), snapshot.Bytes()...)
+	corrupted[len(corrupted)-100] ^= 0xff
+	err = rln5.ImportSnapshot(bytes.NewReader(corrupted))
+	s.ErrorContains(err, "checksum")
+
+	err = rln5.ImportSnapshot(bytes.NewReader(snapshot.Bytes()[:10]))
+	s.Error(err)
+
+	// Snapshots of trees with a different depth are rejected
+	rln4, err := NewWithConfig(TreeDepth15, nil)
+	s.NoError(err)
+	err = rln4.ImportSnapshot(bytes.NewReader(snapshot.Bytes()))
+	s.ErrorContains(err, "depth")
+}
+
+func (s *RLNSuite) TestSnapshotEmptyTree() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	root, err := rln.GetMerkleRoot()
+	s.NoError(err)
+
+	var snapshot bytes.Buffer
+	err = rln.ExportSnapshot(&snapshot)
+	s.NoError(err)
+
+	rln2, err := NewRLN()
+	s.NoError(err)
+
+	err = rln2.ImportSnapshot(&snapshot)
+	s.NoError(err)
+
+	root2, err := rln2.GetMerkleRoot()
+	s.NoError(err)
+	s.Equal(root, root2)
+	s.Equal(uint(0), rln2.LeavesSet())
+}
+
+func (s *RLNSuite) TestSnapshotExportDuringInsertions() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 100; i++ {
+			if err := rln.InsertMember(IDCommitment{byte(i + 1)}); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	// Without metadata, the root follows the 40 bytes header and the leaves follow the root
+	for i := 0; i < 10; i++ {
+		var snapshot bytes.Buffer
+		err = rln.ExportSnapshot(&snapshot)
+		s.NoError(err)
+
+		content := snapshot.Bytes()[:snapshot.Len()-sha256.Size]
+		var root MerkleNode
+		copy(root[:], content[40:72])
+
+		leaves := make([]IDCommitment, (len(content)-72)/32)
+		for j := range leaves {
+			copy(leaves[j][:], content[72+32*j:])
+		}
+
+		s.NoError(checkSnapshotRoot(DefaultTreeDepth, leaves, root))
+	}
+
+	s.NoError(<-done)
+}
@@ -0,0 +1,168 @@
+package rln
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// TreeStats describes the state of the Merkle tree
+type TreeStats struct {
+	// Depth of the tree
+	Depth TreeDepth
+	// Capacity is the maximum number of leaves, 2^depth
+	Capacity uint64
+	// NextIndex is the index used by the next InsertMember
+	NextIndex MembershipIndex
+	// DeletedLeaves is the number of leaves below NextIndex that are zero
+	DeletedLeaves uint
+	// StorageSize is the size in bytes of the tree database. It is 0 if the
+	// tree is not stored in a known path
+	StorageSize int64
+}
+
+// leafCount is the number of non-zero leaves of the tree, so Stats does not need to read every
+// leaf to count the deleted ones. It is unknown for trees opened from disk until Stats counts
+// them, and then updated by every modification of the tree. It is guarded by writeMu
+type leafCount struct {
+	known   bool
+	nonZero uint
+}
+
+// Stats returns information about the tree. The first call on a tree opened from disk
+// reads its leaves to count the deleted ones
+func (r *RLN) Stats() (TreeStats, error) {
+	if err := r.loadTree(); err != nil {
+		return TreeStats{}, err
+	}
+
+	if err := r.lockWrites(); err != nil {
+		return TreeStats{}, err
+	}
+	defer r.writeMu.Unlock()
+
+	nextIndex := MembershipIndex(r.LeavesSet())
+
+	if !r.leafCount.known {
+		var nonZero uint
+		it := r.Leaves(0)
+		for it.Next() {
+			nonZero++
+		}
+		if err := it.Err(); err != nil {
+			return TreeStats{}, err
+		}
+		r.leafCount = leafCount{known: true, nonZero: nonZero}
+	}
+
+	storageSize, err := dirSize(r.treePath)
+	if err != nil {
+		return TreeStats{}, err
+	}
+
+	return TreeStats{
+		Depth:         r.depth,
+		Capacity:      r.capacity(),
+		NextIndex:     nextIndex,
+		DeletedLeaves: uint(nextIndex) - r.leafCount.nonZero,
+		StorageSize:   storageSize,
+	}, nil
+}
+
+// countLeafChanges must be called before inserting `inserted` starting at `index` and removing
+// `removed`. The returned function, called after the modification succeeds, updates the number
+// of non-zero leaves. Only the leaves being replaced are read, and the removed ones again
+// afterwards, since zerokit does not always remove all of them. The count is discarded if a
+// leaf cannot be read, and Stats counts all the leaves again
+func (r *RLN) countLeafChanges(index MembershipIndex, inserted []IDCommitment, removed []MembershipIndex) func() {
+	if !r.leafCount.known {
+		return func() {}
+	}
+
+	removedSet := make(map[MembershipIndex]struct{}, len(removed))
+	for _, i := range removed {
+		removedSet[i] = struct{}{}
+	}
+
+	nextIndex := MembershipIndex(r.w.LeavesSet())
+	before := 0
+	readFailed := false
+	countNonZero := func(i MembershipIndex) int {
+		if i >= MembershipIndex(r.w.LeavesSet()) {
+			return 0
+		}
+		leaf, err := r.w.GetLeaf(i)
+		if err != nil {
+			readFailed = true
+			return 0
+		}
+		if bytes.Equal(leaf, zeroLeaf[:]) {
+			return 0
+		}
+		return 1
+	}
+
+	for i := range inserted {
+		leafIndex := index + MembershipIndex(i)
+		if leafIndex >= nextIndex {
+			break
+		}
+		if _, ok := removedSet[leafIndex]; !ok {
+			before += countNonZero(leafIndex)
+		}
+	}
+	for i := range removedSet {
+		before += countNonZero(i)
+	}
+
+	return func() {
+		after := 0
+		for i, idComm := range inserted {
+			if _, ok := removedSet[index+MembershipIndex(i)]; !ok && idComm != zeroLeaf {
+				after++
+			}
+		}
+		for i := range removedSet {
+			after += countNonZero(i)
+		}
+
+		if readFailed {
+			r.leafCount = leafCount{}
+			return
+		}
+		r.leafCount.nonZero = uint(int(r.leafCount.nonZero) + after - before)
+	}
+}
+
+// zeroLeaf is the value of unset and deleted leaves
+var zeroLeaf IDCommitment
+
+// dirSize returns the total size of the files inside path. Missing paths have a size of 0
+func dirSize(path string) (int64, error) {
+	if path == "" {
+		return 0, nil
+	}
+
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+
+	return size, err
+}
@@ -0,0 +1,98 @@
+package rln
+
+import (
+	"time"
+)
+
+func (s *RLNSuite) TestStats() {
+	rln, err := NewWithConfig(DefaultTreeDepth, &TreeConfig{
+		CacheCapacity: 15000,
+		Mode:          HighThroughput,
+		Compression:   false,
+		FlushInterval: 500 * time.Millisecond,
+		Path:          s.T().TempDir(),
+	})
+	s.NoError(err)
+
+	stats, err := rln.Stats()
+	s.NoError(err)
+	s.Equal(TreeDepth(20), stats.Depth)
+	s.Equal(uint64(1<<20), stats.Capacity)
+	s.Equal(MembershipIndex(0), stats.NextIndex)
+	s.Equal(uint(0), stats.DeletedLeaves)
+
+	for i := 0; i < 5; i++ {
+		err = rln.InsertMember(IDCommitment{byte(i + 1)})
+		s.NoError(err)
+	}
+
+	err = rln.DeleteMember(1)
+	s.NoError(err)
+	err = rln.DeleteMember(3)
+	s.NoError(err)
+
+	err = rln.Flush()
+	s.NoError(err)
+
+	metrics := &recordingMetrics{}
+	rln.SetMetrics(metrics)
+
+	stats, err = rln.Stats()
+	s.NoError(err)
+	s.Equal(MembershipIndex(5), stats.NextIndex)
+	s.Equal(uint(2), stats.DeletedLeaves)
+	s.Greater(stats.StorageSize, int64(0))
+
+	// The deleted leaves are not counted reading the tree
+	s.Empty(metrics.operations)
+
+	// Deleting a leaf twice only counts it once
+	err = rln.DeleteMember(1)
+	s.NoError(err)
+	err = rln.InsertMemberAt(3, IDCommitment{0x10})
+	s.NoError(err)
+	err = rln.InsertMembers(7, []IDCommitment{{0x11}})
+	s.NoError(err)
+	err = rln.AtomicOperation(8, []IDCommitment{{0x12}}, []MembershipIndex{0})
+	s.NoError(err)
+
+	stats, err = rln.Stats()
+	s.NoError(err)
+	s.Equal(MembershipIndex(9), stats.NextIndex)
+	// Leaves 0, 1, 5 and 6
+	s.Equal(uint(4), stats.DeletedLeaves)
+
+	// Counting all the leaves gives the same result
+	rln.leafCount = leafCount{}
+	recounted, err := rln.Stats()
+	s.NoError(err)
+	s.Equal(stats, recounted)
+}
+
+func (s *RLNSuite) TestErrTreeFull() {
+	rln, err := NewWithConfig(15, nil)
+	s.NoError(err)
+
+	capacity := 1 << 15
+
+	err = rln.InsertMembers(1, make([]IDCommitment, capacity))
+	s.ErrorIs(err, ErrTreeFull)
+
+	commitments := make([]IDCommitment, capacity-1)
+	for i := range commitments {
+		commitments[i] = IDCommitment{byte(i), byte(i >> 8), 1}
+	}
+	err = rln.InsertMembers(0, commitments)
+	s.NoError(err)
+
+	err = rln.InsertMember(IDCommitment{0xff})
+	s.NoError(err)
+
+	err = rln.InsertMember(IDCommitment{0xff})
+	s.ErrorIs(err, ErrTreeFull)
+
+	stats, err := rln.Stats()
+	s.NoError(err)
+	s.Equal(uint64(capacity), stats.Capacity)
+	s.Equal(MembershipIndex(capacity), stats.NextIndex)
+}
@@ -0,0 +1,341 @@
+package rln
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	// ErrTreeExists is returned by CreateTree when there is already a tree in the path
+	ErrTreeExists = errors.New("tree already exists")
+	// ErrTreeNotFound is returned by OpenTree when there is no tree created with CreateTree in the path
+	ErrTreeNotFound = errors.New("tree not found")
+	// ErrTreeLocked is returned when the tree is being used by another instance
+	ErrTreeLocked = errors.New("tree is locked by another instance")
+	// ErrClosed is returned by the tree operations of an instance after calling Close
+	ErrClosed = errors.New("instance is closed")
+)
+
+// TreeManifest identifies the membership group a persistent tree belongs to
+type TreeManifest struct {
+	Depth TreeDepth
+	// RLNIdentifier defaults to RLN_IDENTIFIER if empty
+	RLNIdentifier RLNIdentifier
+	// GroupID is chosen by the application, i.e. the chain id and address of the membership contract
+	GroupID string
+}
+
+const treeManifestVersion = 1
+
+type treeManifestJSON struct {
+	Version       int       `json:"version"`
+	Depth         TreeDepth `json:"depth"`
+	RLNIdentifier string    `json:"rln_identifier"`
+	GroupID       string    `json:"group_id"`
+}
+
+func (m TreeManifest) withDefaults() TreeManifest {
+	if m.RLNIdentifier == (RLNIdentifier{}) {
+		m.RLNIdentifier = RLN_IDENTIFIER
+	}
+	return m
+}
+
+// treeManifestPath returns the location of the manifest of a tree stored in `treePath`
+func treeManifestPath(treePath string) string {
+	return filepath.Clean(treePath) + ".manifest.json"
+}
+
+// treeLockPath returns the location of the lock file of a tree stored in `treePath`
+func treeLockPath(treePath string) string {
+	return filepath.Clean(treePath) + ".lock"
+}
+
+func readTreeManifest(path string) (TreeManifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return TreeManifest{}, err
+	}
+
+	var m treeManifestJSON
+	if err := json.Unmarshal(b, &m); err != nil {
+		return TreeManifest{}, fmt.Errorf("invalid tree manifest: %w", err)
+	}
+
+	if m.Version != treeManifestVersion {
+		return TreeManifest{}, fmt.Errorf("unsupported tree manifest version: %d", m.Version)
+	}
+
+	rlnIdentifier, err := hex.DecodeString(m.RLNIdentifier)
+	if err != nil || len(rlnIdentifier) != len(RLNIdentifier{}) {
+		return TreeManifest{}, errors.New("invalid tree manifest: wrong rln identifier")
+	}
+
+	result := TreeManifest{
+		Depth:   m.Depth,
+		GroupID: m.GroupID,
+	}
+	copy(result.RLNIdentifier[:], rlnIdentifier)
+
+	return result, nil
+}
+
+func writeTreeManifest(path string, manifest TreeManifest) error {
+	b, err := json.Marshal(treeManifestJSON{
+		Version:       treeManifestVersion,
+		Depth:         manifest.Depth,
+		RLNIdentifier: hex.EncodeToString(manifest.RLNIdentifier[:]),
+		GroupID:       manifest.GroupID,
+	})
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, b, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// treeLock is an exclusive lock on a tree path, held while the RLN instance is open
+type treeLock struct {
+	f *os.File
+}
+
+// openedTrees contains the paths of the trees opened by this process. zerokit never releases
+// the database of an instance, and opening it again from the same process blocks forever,
+// so paths are not removed on Close
+var openedTrees = struct {
+	sync.Mutex
+	paths map[string]struct{}
+}{paths: make(map[string]struct{})}
+
+func markTreeOpened(treePath string) {
+	openedTrees.Lock()
+	defer openedTrees.Unlock()
+	openedTrees.paths[absPath(treePath)] = struct{}{}
+}
+
+func isTreeOpened(treePath string) bool {
+	openedTrees.Lock()
+	defer openedTrees.Unlock()
+	_, ok := openedTrees.paths[absPath(treePath)]
+	return ok
+}
+
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
+
+func acquireTreeLock(treePath string) (*treeLock, error) {
+	if isTreeOpened(treePath) {
+		return nil, fmt.Errorf("%w: %s was already opened by this process", ErrTreeLocked, treePath)
+	}
+
+	f, err := os.OpenFile(treeLockPath(treePath), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, ErrTreeLocked) {
+			return nil, fmt.Errorf("%w: %s", ErrTreeLocked, treePath)
+		}
+		return nil, err
+	}
+
+	return &treeLock{f: f}, nil
+}
+
+func (l *treeLock) release() error {
+	if err := unlockFile(l.f); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
+
+// TreeLock is the exclusive lock on a tree path held by the instances created with CreateTree
+// and OpenTree
+type TreeLock struct {
+	lock *treeLock
+}
+
+// LockTree takes the lock of the tree in `treePath`, for tools that open the tree with
+// NewWithConfig while no instance created with CreateTree or OpenTree can use it. It fails with
+// ErrTreeLocked if the tree is being used. Instances created with NewWithConfig do not take
+// the lock, so they are not detected
+func LockTree(treePath string) (*TreeLock, error) {
+	lock, err := acquireTreeLock(treePath)
+	if err != nil {
+		return nil, err
+	}
+	return &TreeLock{lock: lock}, nil
+}
+
+// Release releases the lock
+func (l *TreeLock) Release() error {
+	return l.lock.release()
+}
+
+// CreateTree creates a new persistent tree in `treeConfig.Path`, recording the manifest next to it
+// so OpenTree can later check that the tree belongs to the expected group. It fails with
+// ErrTreeExists if the path is already in use. The tree is locked until Close is called.
+// The manifest is stored in a sidecar file instead of the tree metadata because the metadata
+// belongs to the application (SetMetadata), and because the manifest must be checked before
+// opening the database, which can not be opened twice by the same process
+func CreateTree(treeConfig TreeConfig, manifest TreeManifest) (*RLN, error) {
+	if err := treeConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	manifest = manifest.withDefaults()
+
+	if err := os.MkdirAll(filepath.Dir(filepath.Clean(treeConfig.Path)), 0700); err != nil {
+		return nil, err
+	}
+
+	lock, err := acquireTreeLock(treeConfig.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range []string{treeConfig.Path, treeManifestPath(treeConfig.Path)} {
+		if _, err := os.Stat(path); err == nil {
+			lock.release()
+			return nil, fmt.Errorf("%w: %s", ErrTreeExists, path)
+		}
+	}
+
+	// The manifest is written first, so a failure does not leave a database without manifest
+	// that could neither be created nor opened again
+	if err := writeTreeManifest(treeManifestPath(treeConfig.Path), manifest); err != nil {
+		lock.release()
+		return nil, fmt.Errorf("could not write tree manifest: %w", err)
+	}
+
+	r, err := NewWithConfig(manifest.Depth, &treeConfig)
+	if err != nil {
+		os.Remove(treeManifestPath(treeConfig.Path))
+		if _, statErr := os.Stat(treeConfig.Path); statErr == nil {
+			// zerokit might keep the database open, so it must not be opened again by this process
+			markTreeOpened(treeConfig.Path)
+			os.RemoveAll(treeConfig.Path)
+		}
+		lock.release()
+		return nil, err
+	}
+
+	markTreeOpened(treeConfig.Path)
+	r.lock = lock
+	return r, nil
+}
+
+// OpenTree opens a persistent tree created with CreateTree. It fails with ErrTreeNotFound if
+// there is no such tree, and refuses to open trees whose manifest differs from `manifest`.
+// The tree is locked until Close is called
+func OpenTree(treeConfig TreeConfig, manifest TreeManifest) (*RLN, error) {
+	if err := treeConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	manifest = manifest.withDefaults()
+
+	lock, err := acquireTreeLock(treeConfig.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := readTreeManifest(treeManifestPath(treeConfig.Path))
+	if err != nil {
+		lock.release()
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrTreeNotFound, treeConfig.Path)
+		}
+		return nil, err
+	}
+
+	if err := stored.check(manifest); err != nil {
+		lock.release()
+		return nil, fmt.Errorf("tree at %s can not be opened: %w", treeConfig.Path, err)
+	}
+
+	r, err := NewWithConfig(manifest.Depth, &treeConfig)
+	if err != nil {
+		lock.release()
+		return nil, err
+	}
+
+	markTreeOpened(treeConfig.Path)
+	r.lock = lock
+	return r, nil
+}
+
+// check compares the stored manifest against the expected one
+func (m TreeManifest) check(expected TreeManifest) error {
+	if m.Depth != expected.Depth {
+		return fmt.Errorf("it was created with depth %d, expected %d", m.Depth, expected.Depth)
+	}
+
+	if m.RLNIdentifier != expected.RLNIdentifier {
+		return fmt.Errorf("it was created for rln identifier %x, expected %x", m.RLNIdentifier, expected.RLNIdentifier)
+	}
+
+	if m.GroupID != expected.GroupID {
+		return fmt.Errorf("it was created for group %q, expected %q", m.GroupID, expected.GroupID)
+	}
+
+	return nil
+}
+
+// Close flushes the tree and releases the lock taken by CreateTree or OpenTree, so the tree can
+// be opened by another process. Modifications in progress finish before, and afterwards the tree
+// operations return ErrClosed. zerokit does not expose a way to free the instance, so the tree
+// database stays open until the process exits, and can not be opened again by this process
+func (r *RLN) Close() error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if r.closed.Load() {
+		return nil
+	}
+
+	var err error
+	// A tree that was never loaded has nothing to flush
+	if r.treePath != "" && r.treeLoaded() {
+		err = r.Flush()
+	}
+	r.closed.Store(true)
+
+	if r.lock != nil {
+		if lockErr := r.lock.release(); lockErr != nil && err == nil {
+			err = lockErr
+		}
+		r.lock = nil
+	}
+
+	return err
+}
+
+// lockWrites takes writeMu to modify the tree, failing with ErrClosed after Close.
+// writeMu is only held when no error is returned
+func (r *RLN) lockWrites() error {
+	r.writeMu.Lock()
+	if r.closed.Load() {
+		r.writeMu.Unlock()
+		return ErrClosed
+	}
+	return nil
+}
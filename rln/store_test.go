@@ -0,0 +1,147 @@
+package rln
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testTreeConfig(path string) TreeConfig {
+	config := DefaultTreeConfig()
+	config.Path = path
+	return config
+}
+
+func (s *RLNSuite) TestCreateTree() {
+	dir := s.T().TempDir()
+	treePath := filepath.Join(dir, "tree")
+	manifest := TreeManifest{Depth: DefaultTreeDepth, GroupID: "1:0xabcdef"}
+
+	rln, err := CreateTree(testTreeConfig(treePath), manifest)
+	s.NoError(err)
+
+	err = rln.InsertMember(IDCommitment{1})
+	s.NoError(err)
+
+	stored, err := readTreeManifest(treeManifestPath(treePath))
+	s.NoError(err)
+	s.Equal(TreeManifest{Depth: DefaultTreeDepth, RLNIdentifier: RLN_IDENTIFIER, GroupID: "1:0xabcdef"}, stored)
+
+	// The tree is in use
+	_, err = CreateTree(testTreeConfig(treePath), manifest)
+	s.ErrorIs(err, ErrTreeLocked)
+	_, err = OpenTree(testTreeConfig(treePath), manifest)
+	s.ErrorIs(err, ErrTreeLocked)
+	_, err = LockTree(treePath)
+	s.ErrorIs(err, ErrTreeLocked)
+
+	err = rln.Close()
+	s.NoError(err)
+
+	// The instance can not be used once the lock is released
+	err = rln.InsertMember(IDCommitment{2})
+	s.ErrorIs(err, ErrClosed)
+	err = rln.AtomicOperation(1, []IDCommitment{{2}}, nil)
+	s.ErrorIs(err, ErrClosed)
+	_, err = rln.GetLeaf(0)
+	s.ErrorIs(err, ErrClosed)
+	err = rln.Flush()
+	s.ErrorIs(err, ErrClosed)
+	s.NoError(rln.Close())
+
+	// zerokit keeps the database open, so it can not be opened again by this process
+	_, err = OpenTree(testTreeConfig(treePath), manifest)
+	s.ErrorIs(err, ErrTreeLocked)
+
+	// A path with an existing database
+	existingPath := filepath.Join(dir, "existing")
+	err = os.Mkdir(existingPath, 0700)
+	s.NoError(err)
+	_, err = CreateTree(testTreeConfig(existingPath), manifest)
+	s.ErrorIs(err, ErrTreeExists)
+
+	_, err = CreateTree(TreeConfig{Path: filepath.Join(dir, "invalid")}, manifest)
+	s.Error(err)
+
+	// A failure writing the manifest does not leave a database behind
+	failedPath := filepath.Join(dir, "failed")
+	err = os.Mkdir(treeManifestPath(failedPath)+".tmp", 0700)
+	s.NoError(err)
+	_, err = CreateTree(testTreeConfig(failedPath), manifest)
+	s.Error(err)
+	s.NoDirExists(failedPath)
+
+	err = os.Remove(treeManifestPath(failedPath) + ".tmp")
+	s.NoError(err)
+
+	// Neither does a failure creating the instance
+	_, err = CreateTree(testTreeConfig(failedPath), TreeManifest{Depth: 3})
+	s.Error(err)
+	s.NoFileExists(treeManifestPath(failedPath))
+
+	// The path can be used once the cause is fixed
+	rln2, err := CreateTree(testTreeConfig(failedPath), manifest)
+	s.NoError(err)
+	s.NoError(rln2.Close())
+
+	// Missing parent directories are created
+	rln3, err := CreateTree(testTreeConfig(filepath.Join(dir, "parent", "tree")), manifest)
+	s.NoError(err)
+	s.NoError(rln3.Close())
+}
+
+func (s *RLNSuite) TestOpenTree() {
+	dir := s.T().TempDir()
+	manifest := TreeManifest{Depth: DefaultTreeDepth, GroupID: "1:0xabcdef"}
+
+	_, err := OpenTree(testTreeConfig(filepath.Join(dir, "missing")), manifest)
+	s.ErrorIs(err, ErrTreeNotFound)
+
+	treePath := filepath.Join(dir, "tree")
+	err = writeTreeManifest(treeManifestPath(treePath), manifest.withDefaults())
+	s.NoError(err)
+
+	// Mismatched manifests
+	_, err = OpenTree(testTreeConfig(treePath), TreeManifest{Depth: DefaultTreeDepth, GroupID: "5:0xabcdef"})
+	s.ErrorContains(err, "group")
+	_, err = OpenTree(testTreeConfig(treePath), TreeManifest{Depth: TreeDepth15, GroupID: "1:0xabcdef"})
+	s.ErrorContains(err, "depth")
+	_, err = OpenTree(testTreeConfig(treePath), TreeManifest{Depth: DefaultTreeDepth, RLNIdentifier: RLNIdentifier{1}, GroupID: "1:0xabcdef"})
+	s.ErrorContains(err, "rln identifier")
+
+	// The lock was released after the failures
+	rln, err := OpenTree(testTreeConfig(treePath), TreeManifest{Depth: DefaultTreeDepth, RLNIdentifier: RLN_IDENTIFIER, GroupID: "1:0xabcdef"})
+	s.NoError(err)
+
+	err = rln.InsertMember(IDCommitment{1})
+	s.NoError(err)
+
+	err = rln.Close()
+	s.NoError(err)
+}
+
+func TestTreeLock(t *testing.T) {
+	treePath := filepath.Join(t.TempDir(), "tree")
+
+	lock, err := acquireTreeLock(treePath)
+	require.NoError(t, err)
+
+	_, err = acquireTreeLock(treePath)
+	require.ErrorIs(t, err, ErrTreeLocked)
+
+	err = lock.release()
+	require.NoError(t, err)
+
+	lock, err = acquireTreeLock(treePath)
+	require.NoError(t, err)
+	require.NoError(t, lock.release())
+
+	// The exported lock is the same
+	exported, err := LockTree(treePath)
+	require.NoError(t, err)
+	_, err = acquireTreeLock(treePath)
+	require.ErrorIs(t, err, ErrTreeLocked)
+	require.NoError(t, exported.Release())
+}
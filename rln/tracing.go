@@ -0,0 +1,55 @@
+package rln
+
+import (
+	"context"
+)
+
+// Tracer creates the spans of the operations of a RLN instance. It mirrors the OpenTelemetry
+// API without depending on it, so an OpenTelemetry tracer can be used with a small adapter:
+//
+//	type otelTracer struct{ tracer trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, rln.Span) {
+//		ctx, span := t.tracer.Start(ctx, name)
+//		return ctx, otelSpan{span}
+//	}
+//
+// where otelSpan converts the attributes with attribute.Int64, attribute.String, etc.
+type Tracer interface {
+	// Start creates a span that is a child of the span in ctx, if any
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a traced operation. Its methods are called by a single goroutine
+type Span interface {
+	// SetAttribute describes the operation. Values are int, uint, uint64, bool or string
+	SetAttribute(key string, value any)
+	// RecordError indicates that the operation failed
+	RecordError(err error)
+	// End finishes the span
+	End()
+}
+
+// Spans are named "rln.<operation>", and their attributes "rln.<key>"
+const attributePrefix = "rln."
+
+func spanName(op Operation) string {
+	return attributePrefix + string(op)
+}
+
+// SetTracer configures the Tracer used to create a span for every operation of this instance,
+// with the depth of the tree, the sizes of the inputs and the result as attributes. Only the
+// methods receiving a context, like VerifyContext, create spans that are part of an existing
+// trace. Using nil disables tracing
+func (r *RLN) SetTracer(tracer Tracer) {
+	r.tracer = tracer
+}
+
+func setSpanAttributes(span Span, attrs []any) {
+	attrs = redactArgs(attrs)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		if key, ok := attrs[i].(string); ok {
+			span.SetAttribute(attributePrefix+key, attrs[i+1])
+		}
+	}
+}
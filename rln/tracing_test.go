@@ -0,0 +1,148 @@
+package rln
+
+import (
+	"context"
+	"sync"
+)
+
+type recordedSpan struct {
+	name       string
+	parent     string
+	attributes map[string]any
+	err        error
+	ended      bool
+}
+
+func (s *recordedSpan) SetAttribute(key string, value any) {
+	s.attributes[key] = value
+}
+
+func (s *recordedSpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *recordedSpan) End() {
+	s.ended = true
+}
+
+type spanKey struct{}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(string)
+	span := &recordedSpan{name: name, parent: parent, attributes: make(map[string]any)}
+
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+
+	return context.WithValue(ctx, spanKey{}, name), span
+}
+
+func (s *RLNSuite) TestTracer() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	memKeys, err := rln.MembershipKeyGen()
+	s.NoError(err)
+
+	tracer := &recordingTracer{}
+	rln.SetTracer(tracer)
+
+	err = rln.InsertMembers(0, []IDCommitment{memKeys.IDCommitment})
+	s.NoError(err)
+
+	root, err := rln.GetMerkleRoot()
+	s.NoError(err)
+
+	proof, err := rln.GenerateProof([]byte("Hello"), *memKeys, MembershipIndex(0), ToEpoch(1000))
+	s.NoError(err)
+
+	ctx := context.WithValue(context.Background(), spanKey{}, "validate_message")
+
+	_, err = rln.ExtractMetadataContext(ctx, *proof)
+	s.NoError(err)
+
+	res, err := rln.VerifyStrictContext(ctx, []byte("Hello"), *proof, VerifyOptions{
+		ExpectedEpoch:         ToEpoch(1000),
+		ExpectedRLNIdentifier: RLN_IDENTIFIER,
+		Roots:                 []MerkleNode{root},
+	})
+	s.NoError(err)
+	s.True(res.Valid)
+
+	err = rln.InsertMembers(1<<20, []IDCommitment{{2}})
+	s.ErrorIs(err, ErrTreeFull)
+
+	s.Len(tracer.spans, 7)
+	names := make([]string, len(tracer.spans))
+	for i, span := range tracer.spans {
+		names[i] = span.name
+		s.True(span.ended)
+		s.Equal(int(DefaultTreeDepth), span.attributes["rln.depth"])
+	}
+	s.Equal([]string{
+		"rln.insert_members",
+		"rln.get_merkle_root",
+		"rln.generate_proof",
+		"rln.extract_metadata",
+		"rln.poseidon",
+		"rln.verify",
+		"rln.insert_members",
+	}, names)
+
+	s.Equal(1, tracer.spans[0].attributes["rln.count"])
+	s.Equal("", tracer.spans[2].parent)
+	s.Equal("validate_message", tracer.spans[3].parent)
+	s.Equal("rln.extract_metadata", tracer.spans[4].parent)
+	s.Equal("validate_message", tracer.spans[5].parent)
+	s.Equal(true, tracer.spans[5].attributes["rln.valid"])
+	s.ErrorIs(tracer.spans[6].err, ErrTreeFull)
+
+	rln.SetTracer(nil)
+	_, err = rln.GetMerkleRoot()
+	s.NoError(err)
+	s.Len(tracer.spans, 7)
+}
+
+func (s *RLNSuite) TestTracerNestedProofs() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	memKeys, err := rln.MembershipKeyGen()
+	s.NoError(err)
+
+	err = rln.InsertMember(memKeys.IDCommitment)
+	s.NoError(err)
+
+	prover, err := NewLazyProver(DefaultTreeDepth, rln)
+	s.NoError(err)
+
+	// The witness is built on the Go side with a signal hasher other than Keccak256,
+	// and in a prover-only instance
+	rln.SetSignalHasher(PoseidonSignalHasher{})
+	for _, instance := range []*RLN{rln, prover} {
+		tracer := &recordingTracer{}
+		rln.SetTracer(tracer)
+		prover.SetTracer(tracer)
+
+		ctx := context.WithValue(context.Background(), spanKey{}, "send_message")
+		_, err = instance.GenerateProofContext(ctx, []byte("Hello"), *memKeys, MembershipIndex(0), ToEpoch(1000))
+		s.NoError(err)
+
+		parents := make(map[string]string)
+		for _, span := range tracer.spans {
+			s.True(span.ended)
+			parents[span.name] = span.parent
+		}
+		s.Equal(map[string]string{
+			"rln.generate_proof":                  "send_message",
+			"rln.get_merkle_proof":                "rln.generate_proof",
+			"rln.generate_rln_proof_with_witness": "rln.generate_proof",
+		}, parents)
+	}
+}
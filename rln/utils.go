@@ -10,19 +10,40 @@ import (
 	"golang.org/x/crypto/sha3"
 )
 
+// CreateWitness creates the witness for GenerateRLNProofWithWitness, hashing the
+// signal with the KeccakSignalHasher
 func CreateWitness(
 	idSecretHash IDSecretHash,
 	data []byte,
 	epoch [32]byte,
 	merkleProof MerkleProof) RLNWitnessInput {
 
+	// KeccakSignalHasher never fails
+	witness, _ := CreateWitnessWithHasher(KeccakSignalHasher{}, idSecretHash, data, epoch, merkleProof)
+	return witness
+}
+
+// CreateWitnessWithHasher creates the witness for GenerateRLNProofWithWitness, using
+// the specified SignalHasher to map the signal to a field element
+func CreateWitnessWithHasher(
+	hasher SignalHasher,
+	idSecretHash IDSecretHash,
+	data []byte,
+	epoch [32]byte,
+	merkleProof MerkleProof) (RLNWitnessInput, error) {
+
+	x, err := hasher.HashSignal(data)
+	if err != nil {
+		return RLNWitnessInput{}, err
+	}
+
 	return RLNWitnessInput{
 		IDSecretHash:  idSecretHash,
 		MerkleProof:   merkleProof,
-		X:             HashToBN255(data),
+		X:             x,
 		Epoch:         epoch,
 		RlnIdentifier: RLN_IDENTIFIER,
-	}
+	}, nil
 }
 
 func ToIdentityCredentials(groupKeys [][]string) ([]IdentityCredential, error) {
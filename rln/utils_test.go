@@ -2,6 +2,7 @@ package rln
 
 import (
 	"bytes"
+	"encoding/hex"
 	"math/big"
 	"testing"
 
@@ -51,3 +52,15 @@ func TestHashToBN255(t *testing.T) {
 		[32]byte{69, 7, 140, 46, 26, 131, 147, 30, 161, 68, 2, 5, 234, 195, 227, 223, 119, 187, 116, 97, 153, 70, 71, 254, 60, 149, 54, 109, 77, 79, 105, 20},
 		out)
 }
+
+func TestPoseidonHash(t *testing.T) {
+	// Same input as the one used for the zerokit poseidon_hash in TestPoseidon
+	msg1, _ := hex.DecodeString("126f4c026cd731979365f79bd345a46d673c5a3f6f588bdc718e6356d02b6fdc")
+	msg2, _ := hex.DecodeString("1f0e5db2b69d599166ab16219a97b82b662085c93220382b39f9f911d3b943b1")
+
+	hash, err := poseidonHash(Bytes32(msg1), Bytes32(msg2))
+	require.NoError(t, err)
+
+	expectedHash, _ := hex.DecodeString("83e4a6b2dea68aad26f04f32f37ac1e018188a0056b158b2aa026d34266d1f30")
+	require.Equal(t, expectedHash, hash[:])
+}
@@ -0,0 +1,71 @@
+package rln
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// NewVerifier creates a RLN instance that can only verify proofs, using the verifying key in the
+// verification_key.json format. Neither the tree nor the proving key are loaded, so it is much
+// cheaper than a full instance. Verify, VerifyStrict, ExtractMetadata and RecoverIDSecret are
+// done on the Go side, while the tree and proving operations return ErrUnsupported
+func NewVerifier(verifKey []byte) (*RLN, error) {
+	if len(verifKey) == 0 {
+		return nil, errors.New("a verifying key is required")
+	}
+
+	r := &RLN{
+		verifKey: verifKey,
+	}
+
+	// The key is parsed now so an invalid key is reported here instead of in every verification
+	if _, err := r.verifyingKey(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// recoverIDSecret computes the identity secret from the shares of two proofs generated for the
+// same external nullifier, as the intercept of the line that goes through both shares. Like
+// zerokit, a zero secret is returned if the proofs have different external nullifiers.
+// Equivalent to: https://github.com/vacp2p/zerokit/blob/v0.3.5/rln/src/public.rs (recover_id_secret)
+func recoverIDSecret(proof1 RateLimitProof, proof2 RateLimitProof) (IDSecretHash, error) {
+	externalNullifier1, err := poseidonHash(proof1.Epoch, proof1.RLNIdentifier)
+	if err != nil {
+		return IDSecretHash{}, err
+	}
+
+	externalNullifier2, err := poseidonHash(proof2.Epoch, proof2.RLNIdentifier)
+	if err != nil {
+		return IDSecretHash{}, err
+	}
+
+	if externalNullifier1 != externalNullifier2 {
+		return IDSecretHash{}, nil
+	}
+
+	modulus := fr.Modulus()
+	x1, y1 := toFieldElement(proof1.ShareX), toFieldElement(proof1.ShareY)
+	x2, y2 := toFieldElement(proof2.ShareX), toFieldElement(proof2.ShareY)
+
+	dx := new(big.Int).Sub(x2, x1)
+	dx.Mod(dx, modulus)
+	if dx.Sign() == 0 {
+		return IDSecretHash{}, errors.New("could not recover the identity secret: both proofs have the same share x")
+	}
+
+	// slope = (y2 - y1) / (x2 - x1)
+	slope := new(big.Int).Sub(y2, y1)
+	slope.Mul(slope, dx.ModInverse(dx, modulus))
+	slope.Mod(slope, modulus)
+
+	// secret = y1 - slope * x1
+	secret := new(big.Int).Mul(slope, x1)
+	secret.Sub(y1, secret)
+	secret.Mod(secret, modulus)
+
+	return BigIntToBytes32(secret), nil
+}
@@ -0,0 +1,96 @@
+package rln
+
+func (s *RLNSuite) TestVerifier() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	memKeys, err := rln.MembershipKeyGen()
+	s.NoError(err)
+
+	err = rln.InsertMember(memKeys.IDCommitment)
+	s.NoError(err)
+
+	root, err := rln.GetMerkleRoot()
+	s.NoError(err)
+
+	epoch := ToEpoch(1000)
+
+	proof1, err := rln.GenerateProof([]byte("Hello"), *memKeys, MembershipIndex(0), epoch)
+	s.NoError(err)
+
+	proof2, err := rln.GenerateProof([]byte("World"), *memKeys, MembershipIndex(0), epoch)
+	s.NoError(err)
+
+	_, err = NewVerifier(nil)
+	s.Error(err)
+
+	_, err = NewVerifier([]byte("{}"))
+	s.Error(err)
+
+	verifKey, err := builtinVerifyingKey(DefaultTreeDepth)
+	s.NoError(err)
+
+	verifier, err := NewVerifier(verifKey)
+	s.NoError(err)
+
+	verified, err := verifier.Verify([]byte("Hello"), *proof1, root)
+	s.NoError(err)
+	s.True(verified)
+
+	verified, err = verifier.Verify([]byte("World"), *proof1, root)
+	s.NoError(err)
+	s.False(verified)
+
+	verified, err = verifier.Verify([]byte("Hello"), *proof1, MerkleNode{1})
+	s.NoError(err)
+	s.False(verified)
+
+	res, err := verifier.VerifyStrict([]byte("World"), *proof2, VerifyOptions{
+		ExpectedEpoch:         epoch,
+		ExpectedRLNIdentifier: RLN_IDENTIFIER,
+		Roots:                 []MerkleNode{root},
+	})
+	s.NoError(err)
+	s.True(res.Valid)
+
+	// Metadata and secret recovery match the ones calculated by zerokit
+	expectedMetadata, err := rln.ExtractMetadata(*proof1)
+	s.NoError(err)
+	metadata, err := verifier.ExtractMetadata(*proof1)
+	s.NoError(err)
+	s.Equal(expectedMetadata, metadata)
+
+	expectedSecret, err := rln.RecoverIDSecret(*proof1, *proof2)
+	s.NoError(err)
+	secret, err := verifier.RecoverIDSecret(*proof1, *proof2)
+	s.NoError(err)
+	s.Equal(memKeys.IDSecretHash, secret)
+	s.Equal(expectedSecret, secret)
+
+	_, err = verifier.RecoverIDSecret(*proof1, *proof1)
+	s.Error(err)
+
+	proof3, err := rln.GenerateProof([]byte("World"), *memKeys, MembershipIndex(0), ToEpoch(1001))
+	s.NoError(err)
+	secret, err = verifier.RecoverIDSecret(*proof1, *proof3)
+	s.NoError(err)
+	s.Equal(IDSecretHash{}, secret)
+
+	// Tree and proving operations are not available
+	s.ErrorIs(verifier.InsertMember(memKeys.IDCommitment), ErrUnsupported)
+	_, err = verifier.GetMerkleRoot()
+	s.ErrorIs(err, ErrUnsupported)
+	_, err = verifier.GenerateProof([]byte("Hello"), *memKeys, MembershipIndex(0), epoch)
+	s.ErrorIs(err, ErrUnsupported)
+	_, err = verifier.MembershipKeyGen()
+	s.ErrorIs(err, ErrUnsupported)
+	s.Equal(uint(0), verifier.LeavesSet())
+	_, err = verifier.Stats()
+	s.ErrorIs(err, ErrUnsupported)
+	_, err = verifier.GetLeaves(0, 1)
+	s.ErrorIs(err, ErrUnsupported)
+	it := verifier.Leaves(0)
+	s.False(it.Next())
+	s.ErrorIs(it.Err(), ErrUnsupported)
+	s.ErrorIs(verifier.EnableIndex(), ErrUnsupported)
+}
@@ -0,0 +1,104 @@
+package rln
+
+import (
+	"context"
+)
+
+// VerifyCheck identifies each one of the checks performed by VerifyStrict
+type VerifyCheck int
+
+const (
+	// CheckNone is reported when all the checks passed
+	CheckNone VerifyCheck = iota
+	// CheckEpoch is reported when the proof epoch does not match the expected epoch
+	CheckEpoch
+	// CheckRLNIdentifier is reported when the proof RLN identifier does not match the expected one
+	CheckRLNIdentifier
+	// CheckRoot is reported when the proof root is not part of the acceptable roots
+	CheckRoot
+	// CheckProof is reported when the zkSNARK proof is not valid for the signal
+	CheckProof
+)
+
+func (c VerifyCheck) String() string {
+	switch c {
+	case CheckNone:
+		return "none"
+	case CheckEpoch:
+		return "epoch"
+	case CheckRLNIdentifier:
+		return "rln_identifier"
+	case CheckRoot:
+		return "root"
+	case CheckProof:
+		return "proof"
+	default:
+		return "unknown"
+	}
+}
+
+// VerifyOptions contains the values a proof must match to be accepted by VerifyStrict
+type VerifyOptions struct {
+	// ExpectedEpoch is the epoch the proof must have been generated for
+	ExpectedEpoch Epoch
+	// ExpectedRLNIdentifier is the RLN identifier the proof must have been generated for.
+	// Most applications should use RLN_IDENTIFIER
+	ExpectedRLNIdentifier RLNIdentifier
+	// Roots should contain a sequence of roots in the acceptable window.
+	// If empty, the validity check for the proof's root is skipped
+	Roots []MerkleNode
+}
+
+// VerifyResult is the outcome of VerifyStrict. When Valid is false,
+// FailedCheck indicates the first check that did not pass
+type VerifyResult struct {
+	Valid       bool
+	FailedCheck VerifyCheck
+}
+
+func failedCheck(check VerifyCheck) VerifyResult {
+	return VerifyResult{Valid: false, FailedCheck: check}
+}
+
+// VerifyStrict verifies a proof like Verify does, but additionally checks that the proof
+// was generated for the expected epoch and RLN identifier. The cheap checks on the public
+// inputs are done before calling the zkSNARK verifier. An error is only returned if the
+// verification could not be executed
+func (r *RLN) VerifyStrict(data []byte, proof RateLimitProof, opts VerifyOptions) (VerifyResult, error) {
+	return r.VerifyStrictContext(context.Background(), data, proof, opts)
+}
+
+// VerifyStrictContext is like VerifyStrict, but the span of the zkSNARK verification is a child of the span in ctx
+func (r *RLN) VerifyStrictContext(ctx context.Context, data []byte, proof RateLimitProof, opts VerifyOptions) (VerifyResult, error) {
+	if proof.Epoch != opts.ExpectedEpoch {
+		return failedCheck(CheckEpoch), nil
+	}
+
+	if proof.RLNIdentifier != opts.ExpectedRLNIdentifier {
+		return failedCheck(CheckRLNIdentifier), nil
+	}
+
+	if len(opts.Roots) != 0 && !containsRoot(opts.Roots, proof.MerkleRoot) {
+		return failedCheck(CheckRoot), nil
+	}
+
+	verified, err := r.VerifyContext(ctx, data, proof, opts.Roots...)
+	if err != nil {
+		return VerifyResult{}, err
+	}
+
+	if !verified {
+		return failedCheck(CheckProof), nil
+	}
+
+	return VerifyResult{Valid: true, FailedCheck: CheckNone}, nil
+}
+
+func containsRoot(roots []MerkleNode, root MerkleNode) bool {
+	for _, r := range roots {
+		if r == root {
+			return true
+		}
+	}
+	return false
+}
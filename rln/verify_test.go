@@ -0,0 +1,159 @@
+package rln
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func (s *RLNSuite) TestVerifyStrict() {
+	rln, err := NewRLN()
+	s.NoError(err)
+
+	memKeys, err := rln.MembershipKeyGen()
+	s.NoError(err)
+
+	err = rln.InsertMember(memKeys.IDCommitment)
+	s.NoError(err)
+
+	root, err := rln.GetMerkleRoot()
+	s.NoError(err)
+
+	msg := []byte("Hello")
+	epoch := ToEpoch(1000)
+
+	proofRes, err := rln.GenerateProof(msg, *memKeys, MembershipIndex(0), epoch)
+	s.NoError(err)
+
+	opts := VerifyOptions{
+		ExpectedEpoch:         epoch,
+		ExpectedRLNIdentifier: RLN_IDENTIFIER,
+		Roots:                 []MerkleNode{root},
+	}
+
+	// all checks pass
+	res, err := rln.VerifyStrict(msg, *proofRes, opts)
+	s.NoError(err)
+	s.True(res.Valid)
+	s.Equal(CheckNone, res.FailedCheck)
+
+	// proof generated for a different epoch
+	wrongEpoch := opts
+	wrongEpoch.ExpectedEpoch = ToEpoch(999)
+	res, err = rln.VerifyStrict(msg, *proofRes, wrongEpoch)
+	s.NoError(err)
+	s.False(res.Valid)
+	s.Equal(CheckEpoch, res.FailedCheck)
+
+	// proof generated for a different application
+	wrongIdentifier := opts
+	wrongIdentifier.ExpectedRLNIdentifier = RLNIdentifier{0x01}
+	res, err = rln.VerifyStrict(msg, *proofRes, wrongIdentifier)
+	s.NoError(err)
+	s.False(res.Valid)
+	s.Equal(CheckRLNIdentifier, res.FailedCheck)
+
+	// root outside of the acceptable window
+	wrongRoot := opts
+	wrongRoot.Roots = []MerkleNode{{0x01}}
+	res, err = rln.VerifyStrict(msg, *proofRes, wrongRoot)
+	s.NoError(err)
+	s.False(res.Valid)
+	s.Equal(CheckRoot, res.FailedCheck)
+
+	// signal does not match the proof
+	res, err = rln.VerifyStrict([]byte("different message"), *proofRes, opts)
+	s.NoError(err)
+	s.False(res.Valid)
+	s.Equal(CheckProof, res.FailedCheck)
+	s.Equal("proof", res.FailedCheck.String())
+}
+
+// verifyFixture creates an instance with a member and a valid proof of it
+func verifyFixture(tb testing.TB) (*RLN, *RateLimitProof, MerkleNode) {
+	rln, err := NewRLN()
+	require.NoError(tb, err)
+
+	memKeys, err := rln.MembershipKeyGen()
+	require.NoError(tb, err)
+
+	err = rln.InsertMember(memKeys.IDCommitment)
+	require.NoError(tb, err)
+
+	root, err := rln.GetMerkleRoot()
+	require.NoError(tb, err)
+
+	proof, err := rln.GenerateProof(benchmarkSignal, *memKeys, MembershipIndex(0), ToEpoch(1000))
+	require.NoError(tb, err)
+
+	return rln, proof, root
+}
+
+var benchmarkSignal = bytes.Repeat([]byte{0xab}, 1024)
+
+func (s *RLNSuite) TestVerifyAllocations() {
+	rln, proof, root := verifyFixture(s.T())
+	verifier := rln.NewBufferedVerifier()
+
+	verified, err := rln.Verify(benchmarkSignal, *proof, root)
+	s.NoError(err)
+	s.True(verified)
+
+	verified, err = verifier.Verify(benchmarkSignal, *proof, root)
+	s.NoError(err)
+	s.True(verified)
+
+	// Only the result of zerokit, which is passed by reference, is allocated
+	allocs := testing.AllocsPerRun(5, func() {
+		_, _ = rln.Verify(benchmarkSignal, *proof, root)
+	})
+	s.LessOrEqual(allocs, float64(1))
+
+	allocs = testing.AllocsPerRun(5, func() {
+		_, _ = verifier.Verify(benchmarkSignal, *proof, root)
+	})
+	s.LessOrEqual(allocs, float64(1))
+}
+
+func BenchmarkVerify(b *testing.B) {
+	rln, proof, root := verifyFixture(b)
+
+	b.Run("RLN", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := rln.Verify(benchmarkSignal, *proof, root); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("BufferedVerifier", func(b *testing.B) {
+		verifier := rln.NewBufferedVerifier()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := verifier.Verify(benchmarkSignal, *proof, root); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkSerializeWithData(b *testing.B) {
+	proof := RateLimitProof{}
+
+	b.Run("serializeWithData", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = proof.serializeWithData(benchmarkSignal)
+		}
+	})
+
+	b.Run("appendWithData", func(b *testing.B) {
+		var buf []byte
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf = proof.appendWithData(buf[:0], benchmarkSignal)
+		}
+	})
+}
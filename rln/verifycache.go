@@ -0,0 +1,130 @@
+package rln
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+)
+
+// verifyCacheKey is the digest of the serialized proof, signal and roots
+type verifyCacheKey [sha256.Size]byte
+
+type verifyCacheEntry struct {
+	key       verifyCacheKey
+	valid     bool
+	expiresAt time.Time
+}
+
+// VerifyCache remembers the outcome of the verification of proofs, so a proof received several
+// times, i.e. from different peers, is verified once. Entries are keyed by the proof, the signal
+// and the roots used to verify it, and are kept for a limited time. The least recently used
+// entries are evicted when the cache is full. It is safe for concurrent use, but should only be
+// shared by instances with the same circuit and SignalHasher
+type VerifyCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[verifyCacheKey]*list.Element
+	lru     *list.List
+}
+
+// NewVerifyCache creates a VerifyCache that keeps up to `size` outcomes for `ttl`.
+// Both must be positive
+func NewVerifyCache(size int, ttl time.Duration) (*VerifyCache, error) {
+	if size <= 0 {
+		return nil, errors.New("the size of the cache must be positive")
+	}
+	if ttl <= 0 {
+		return nil, errors.New("the ttl of the cache must be positive")
+	}
+
+	return &VerifyCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[verifyCacheKey]*list.Element),
+		lru:     list.New(),
+	}, nil
+}
+
+func (c *VerifyCache) get(key verifyCacheKey) (valid bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return false, false
+	}
+
+	entry := element.Value.(*verifyCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		return false, false
+	}
+
+	c.lru.MoveToFront(element)
+	return entry.valid, true
+}
+
+func (c *VerifyCache) add(key verifyCacheKey, valid bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*verifyCacheEntry)
+		entry.valid = valid
+		entry.expiresAt = expiresAt
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&verifyCacheEntry{key: key, valid: valid, expiresAt: expiresAt})
+
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*verifyCacheEntry).key)
+	}
+}
+
+// Len returns the number of outcomes in the cache, including the expired ones not removed yet
+func (c *VerifyCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Purge removes all the outcomes
+func (c *VerifyCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[verifyCacheKey]*list.Element)
+	c.lru.Init()
+}
+
+// SetVerifyCache configures the cache used by Verify, VerifyStrict and BufferedVerifier to skip
+// the verification of proofs already verified with the same signal and roots. Only the outcomes
+// of verifications that did not fail are cached. Using nil disables the cache. The signal hasher
+// is not part of the cache key, so a cache must not be shared by instances with different
+// SignalHashers
+func (r *RLN) SetVerifyCache(cache *VerifyCache) {
+	r.verifyCache = cache
+}
+
+// cacheKey returns the digest of the input of verify_with_roots, which must be already serialized
+func (b *verifyBuffers) cacheKey() verifyCacheKey {
+	if b.hash == nil {
+		b.hash = sha256.New()
+	}
+	b.hash.Reset()
+	b.hash.Write(b.proof)
+	b.hash.Write(b.roots)
+	b.digest = b.hash.Sum(b.digest[:0])
+
+	var key verifyCacheKey
+	copy(key[:], b.digest)
+	return key
+}
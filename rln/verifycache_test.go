@@ -0,0 +1,136 @@
+package rln
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func (s *RLNSuite) TestVerifyCache() {
+	rln, proof, root := verifyFixture(s.T())
+
+	metrics := &recordingMetrics{}
+	rln.SetMetrics(metrics)
+
+	cache, err := NewVerifyCache(16, time.Minute)
+	s.NoError(err)
+	rln.SetVerifyCache(cache)
+
+	for i := 0; i < 3; i++ {
+		valid, err := rln.Verify(benchmarkSignal, *proof, root)
+		s.NoError(err)
+		s.True(valid)
+	}
+	s.Equal(1, metrics.cacheMiss)
+	s.Equal(2, metrics.cacheHits)
+	s.Equal(1, cache.Len())
+
+	// Invalid outcomes are cached too
+	for i := 0; i < 2; i++ {
+		valid, err := rln.Verify([]byte("different message"), *proof, root)
+		s.NoError(err)
+		s.False(valid)
+	}
+	s.Equal(2, metrics.cacheMiss)
+	s.Equal(3, metrics.cacheHits)
+
+	// The roots are part of the key
+	valid, err := rln.Verify(benchmarkSignal, *proof, MerkleNode{0x01})
+	s.NoError(err)
+	s.False(valid)
+	s.Equal(3, metrics.cacheMiss)
+	s.Equal(3, cache.Len())
+
+	// The BufferedVerifier shares the cache of its instance
+	valid, err = rln.NewBufferedVerifier().Verify(benchmarkSignal, *proof, root)
+	s.NoError(err)
+	s.True(valid)
+	s.Equal(4, metrics.cacheHits)
+
+	// Metrics that do not implement VerifyCacheMetrics only receive the operations
+	rln.SetMetrics(struct{ Metrics }{metrics})
+	valid, err = rln.Verify(benchmarkSignal, *proof, root)
+	s.NoError(err)
+	s.True(valid)
+	s.Equal(4, metrics.cacheHits)
+	s.Equal(OpVerify, metrics.operations[len(metrics.operations)-1].op)
+	rln.SetMetrics(metrics)
+
+	// The outcomes obtained with another signal hasher are discarded
+	rln.SetSignalHasher(PoseidonSignalHasher{})
+	s.Equal(0, cache.Len())
+	valid, err = rln.Verify(benchmarkSignal, *proof, root)
+	s.NoError(err)
+	s.False(valid)
+	s.Equal(4, metrics.cacheMiss)
+
+	rln.SetSignalHasher(nil)
+	valid, err = rln.Verify(benchmarkSignal, *proof, root)
+	s.NoError(err)
+	s.True(valid)
+	s.Equal(5, metrics.cacheMiss)
+
+	cache.Purge()
+	s.Equal(0, cache.Len())
+
+	rln.SetVerifyCache(nil)
+	valid, err = rln.Verify(benchmarkSignal, *proof, root)
+	s.NoError(err)
+	s.True(valid)
+	s.Equal(5, metrics.cacheMiss)
+	s.Equal(0, cache.Len())
+}
+
+func TestVerifyCacheEviction(t *testing.T) {
+	cache, err := NewVerifyCache(2, time.Minute)
+	require.NoError(t, err)
+
+	cache.add(verifyCacheKey{1}, true)
+	cache.add(verifyCacheKey{2}, false)
+
+	// Using the first key makes the second one the least recently used
+	valid, ok := cache.get(verifyCacheKey{1})
+	require.True(t, ok)
+	require.True(t, valid)
+
+	cache.add(verifyCacheKey{3}, true)
+	require.Equal(t, 2, cache.Len())
+
+	_, ok = cache.get(verifyCacheKey{2})
+	require.False(t, ok)
+
+	valid, ok = cache.get(verifyCacheKey{3})
+	require.True(t, ok)
+	require.True(t, valid)
+}
+
+func TestVerifyCacheExpiration(t *testing.T) {
+	cache, err := NewVerifyCache(2, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	cache.add(verifyCacheKey{1}, true)
+	_, ok := cache.get(verifyCacheKey{1})
+	require.True(t, ok)
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, ok = cache.get(verifyCacheKey{1})
+	require.False(t, ok)
+	require.Equal(t, 0, cache.Len())
+}
+
+func TestVerifyCacheInvalidArguments(t *testing.T) {
+	for _, tc := range []struct {
+		size int
+		ttl  time.Duration
+	}{
+		{size: 0, ttl: time.Minute},
+		{size: -1, ttl: time.Minute},
+		{size: 16, ttl: 0},
+		{size: 16, ttl: -time.Second},
+	} {
+		_, err := NewVerifyCache(tc.size, tc.ttl)
+		require.Error(t, err, "size %d, ttl %s", tc.size, tc.ttl)
+	}
+}